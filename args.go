@@ -0,0 +1,173 @@
+package main
+
+import "fmt"
+
+// kwargs is the keyword arguments given to a function call. The order is kept.
+// nil kwargs means no keyword argument is given.
+type kwargs struct {
+	names []string
+	vals  map[string]obj
+}
+
+// set adds the keyword argument. It fails when the name is already given.
+func (kw *kwargs) set(name string, o obj) error {
+	if _, ok := kw.vals[name]; ok {
+		return fmt.Errorf("duplicate keyword argument %s", name)
+	}
+
+	kw.names = append(kw.names, name)
+	kw.vals[name] = o
+	return nil
+}
+
+func (kw *kwargs) get(name string) (obj, bool) {
+	if kw == nil {
+		return nil, false
+	}
+
+	o, ok := kw.vals[name]
+	return o, ok
+}
+
+func (kw *kwargs) size() int {
+	if kw == nil {
+		return 0
+	}
+
+	return len(kw.names)
+}
+
+// check fails if the keyword argument which is not in allowed is given to the function.
+func (kw *kwargs) check(fn string, allowed []string) error {
+	if kw == nil {
+		return nil
+	}
+
+	for _, name := range kw.names {
+		found := false
+		for _, a := range allowed {
+			if name == a {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("unknown keyword argument %s to %s()", name, fn)
+		}
+	}
+
+	return nil
+}
+
+func newkwargs() *kwargs {
+	return &kwargs{vals: map[string]obj{}}
+}
+
+// param is a parameter of the user-defined function.
+type param struct {
+	name string
+	// dflt is the default value, which is evaluated when the function is defined.
+	// nil if the param is required.
+	dflt obj
+	// variadic param collects the rest positional args into list,
+	// and kwvariadic param collects the rest keyword args into dict.
+	variadic   bool
+	kwvariadic bool
+	// iface is the interface which the arg must implement. nil if the param is not annotated.
+	iface *oInterface
+}
+
+// bindargs decides the values of the params from the args in the manner of Python.
+// The returned values are in the same order as params.
+func bindargs(fn string, params []*param, args []obj, kw *kwargs) ([]obj, error) {
+	vals := make([]obj, len(params))
+
+	// positional args
+	var rest *oList
+	var kwrest *oDict
+	for i, p := range params {
+		if p.variadic {
+			rest = &oList{vals: []obj{}}
+			vals[i] = rest
+		} else if p.kwvariadic {
+			kwrest = &oDict{dict: newdict()}
+			vals[i] = kwrest
+		}
+	}
+
+	pos := 0
+	for _, a := range args {
+		for pos < len(params) && (params[pos].variadic || params[pos].kwvariadic) {
+			pos++
+		}
+
+		if pos == len(params) {
+			if rest == nil {
+				return nil, fmt.Errorf("too many arguments to %s()", fn)
+			}
+			rest.vals = append(rest.vals, a)
+			continue
+		}
+
+		vals[pos] = a
+		pos++
+	}
+
+	// keyword args
+	if kw != nil {
+		for _, name := range kw.names {
+			found := false
+			for i, p := range params {
+				if p.name != name || p.variadic || p.kwvariadic {
+					continue
+				}
+
+				if vals[i] != nil {
+					return nil, fmt.Errorf("multiple values for argument %s to %s()", name, fn)
+				}
+
+				vals[i] = kw.vals[name]
+				found = true
+				break
+			}
+
+			if found {
+				continue
+			}
+
+			if kwrest == nil {
+				return nil, fmt.Errorf("unknown keyword argument %s to %s()", name, fn)
+			}
+			if err := kwrest.dict.set(newstr(name), kw.vals[name]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// annotations. The default values are not checked.
+	for i, p := range params {
+		if p.iface == nil || vals[i] == nil {
+			continue
+		}
+
+		if err := p.iface.check(vals[i]); err != nil {
+			return nil, fmt.Errorf("argument %s to %s(): %s", p.name, fn, err)
+		}
+	}
+
+	// defaults
+	for i, p := range params {
+		if vals[i] != nil {
+			continue
+		}
+
+		if p.dflt == nil {
+			return nil, fmt.Errorf("missing argument %s to %s()", p.name, fn)
+		}
+
+		vals[i] = p.dflt
+	}
+
+	return vals, nil
+}
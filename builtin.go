@@ -3,92 +3,256 @@ package main
 import (
 	"fmt"
 	"io"
-	"os"
+	"math"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
 
-var printer io.Writer
-
-var builtinFns = map[string]*obj{
-	"env": &obj{
-		typ:  tBuiltinFunc,
+var builtinFns = map[string]obj{
+	"bool": tBool,
+	"dict": tDict,
+	"env": &oBuiltinFunc{
 		name: "env",
-		bfnbody: func(args ...*obj) (*obj, error) {
-			fmt.Fprintln(printer, env)
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			fmt.Fprintln(env.stdout, env)
 			return NIL, nil
 		},
 	},
-	"exit": &obj{
-		typ:  tBuiltinFunc,
+	"exit": &oBuiltinFunc{
 		name: "exit",
-		bfnbody: func(args ...*obj) (*obj, error) {
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
 			if len(args) != 1 {
 				return NIL, fmt.Errorf("argument mismatch to exit(): 1 args required")
 			}
-			if args[0].typ != tI64 {
+			code, ok := args[0].(*oI64)
+			if !ok {
 				return NIL, fmt.Errorf("exit() arg must be i64")
 			}
 
-			os.Exit(int(args[0].ival))
-			return NIL, nil // unreachable
+			return NIL, &errExit{code: int(code.val)}
 		},
 	},
-	"len": &obj{
-		typ:  tBuiltinFunc,
+	"f64":   tF64,
+	"file":  tFile,
+	"float": tF64,
+	"fprintf": &oBuiltinFunc{
+		name: "fprintf",
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			if len(args) < 2 {
+				return NIL, fmt.Errorf("argument mismatch to fprintf(): at least 2 args required")
+			}
+
+			f, ok := args[0].(*oFile)
+			if !ok {
+				return NIL, fmt.Errorf("fprintf() first arg must be file but got %s", args[0].typename())
+			}
+
+			s, err := sprintfargs("fprintf", args[1:])
+			if err != nil {
+				return NIL, err
+			}
+
+			// stdout and stderr are written to the environment so that they can be captured
+			switch f.fd {
+			case 1:
+				_, err = io.WriteString(env.stdout, s)
+			case 2:
+				_, err = io.WriteString(env.stderr, s)
+			default:
+				_, err = unix.Write(f.fd, []byte(s))
+			}
+			if err != nil {
+				return NIL, fmt.Errorf("fprintf(): %w", err)
+			}
+
+			return NIL, nil
+		},
+	},
+	"i64": tI64,
+	"implements": &oBuiltinFunc{
+		name: "implements",
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			if len(args) != 2 {
+				return NIL, fmt.Errorf("argument mismatch to implements(): 2 args required")
+			}
+
+			iface, ok := args[1].(*oInterface)
+			if !ok {
+				return NIL, fmt.Errorf("implements() second arg must be interface but got %s", args[1].typename())
+			}
+
+			return newbool(iface.implementedby(args[0])), nil
+		},
+	},
+	"int": tI64,
+	"isinstance": &oBuiltinFunc{
+		name: "isinstance",
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			if len(args) != 2 {
+				return NIL, fmt.Errorf("argument mismatch to isinstance(): 2 args required")
+			}
+
+			ok, err := isinstance(args[0], args[1])
+			if err != nil {
+				return NIL, fmt.Errorf("isinstance(): %w", err)
+			}
+
+			return newbool(ok), nil
+		},
+	},
+	"len": &oBuiltinFunc{
 		name: "len",
-		bfnbody: func(args ...*obj) (*obj, error) {
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
 			if len(args) != 1 {
 				return NIL, fmt.Errorf("argument mismatch to len(): 1 arg required")
 			}
 
 			target := args[0]
-			if !target.isiterable() {
+			if s, ok := target.(*oStruct); ok {
+				n, ok, err := s.def.len(s)
+				if err != nil {
+					return NIL, err
+				}
+
+				if ok {
+					return &oI64{val: int64(n)}, nil
+				}
+			}
+
+			if !target.isIterable() {
 				return NIL, fmt.Errorf("len() of %s is undefined", target)
 			}
 
-			return &obj{typ: tI64, ival: int64(target.iterator().size())}, nil
+			it, err := target.iterator()
+			if err != nil {
+				return NIL, err
+			}
+
+			return &oI64{val: int64(it.size())}, nil
 		},
 	},
-	"print": &obj{
-		typ:  tBuiltinFunc,
+	"print": &oBuiltinFunc{
 		name: "print",
-		bfnbody: func(args ...*obj) (*obj, error) {
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			// sep is put between args, end is put at the end
+			sep, end := " ", "\n"
+			if o, ok := kw.get("sep"); ok {
+				sep = o.String()
+			}
+
+			if o, ok := kw.get("end"); ok {
+				end = o.String()
+			}
+
 			for i, arg := range args {
-				fmt.Fprint(printer, arg)
+				s, err := strof(arg)
+				if err != nil {
+					return NIL, err
+				}
+
+				fmt.Fprint(env.stdout, s)
 				if i != len(args)-1 {
-					fmt.Fprint(printer, " ")
+					fmt.Fprint(env.stdout, sep)
 				}
 			}
 
-			fmt.Fprintln(printer)
+			fmt.Fprint(env.stdout, end)
 
 			return NIL, nil
 		},
+		kwnames: []string{"sep", "end"},
 	},
-	"syscall": &obj{
-		typ:  tBuiltinFunc,
+	"list": tList,
+	"printf": &oBuiltinFunc{
+		name: "printf",
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			s, err := sprintfargs("printf", args)
+			if err != nil {
+				return NIL, err
+			}
+
+			fmt.Fprint(env.stdout, s)
+
+			return NIL, nil
+		},
+	},
+	"range": &oBuiltinFunc{
+		name: "range",
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			if len(args) < 1 || 3 < len(args) {
+				return NIL, fmt.Errorf("argument mismatch to range(): 1, 2 or 3 args required")
+			}
+
+			vals := []int64{}
+			for _, arg := range args {
+				i, ok := arg.(*oI64)
+				if !ok {
+					return NIL, fmt.Errorf("range() argument must be i64 but got %s", arg.typename())
+				}
+				vals = append(vals, i.val)
+			}
+
+			// range(stop), range(start, stop) or range(start, stop, step)
+			r := &oRange{stop: vals[0], step: 1}
+			if len(vals) >= 2 {
+				r.start, r.stop = vals[0], vals[1]
+			}
+
+			if len(vals) == 3 {
+				r.step = vals[2]
+			}
+
+			if r.step == 0 {
+				return NIL, fmt.Errorf("range() step cannot be zero")
+			}
+
+			if rangelen(r.start, r.stop, r.step) > math.MaxInt {
+				return NIL, fmt.Errorf("range() has too many values")
+			}
+
+			return r, nil
+		},
+	},
+	"set": tSet,
+	"sprintf": &oBuiltinFunc{
+		name: "sprintf",
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			s, err := sprintfargs("sprintf", args)
+			if err != nil {
+				return NIL, err
+			}
+
+			return newstr(s), nil
+		},
+	},
+	"str": tStr,
+	"syscall": &oBuiltinFunc{
 		name: "syscall",
-		bfnbody: func(args ...*obj) (*obj, error) {
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
 			if len(args) != 4 {
 				return NIL, fmt.Errorf("argument mismatch to syscall(): 4 args required")
 			}
 
-			toptr := func(o *obj) (uintptr, error) {
-				switch o.typ {
-				case tI64:
-					return uintptr(o.ival), nil
-				case tStr:
-					o.bytes = append(o.bytes, 0)
-					return uintptr(unsafe.Pointer(&o.bytes[0])), nil
+			toptr := func(o obj) (uintptr, error) {
+				switch v := o.(type) {
+				case *oI64:
+					return uintptr(v.val), nil
+				case *oFile:
+					return uintptr(v.fd), nil
+				case *oStr:
+					// the NUL terminator is put just past the end so the str itself is unchanged,
+					// while the kernel can still write into the same bytes (e.g. read(2)).
+					b := append(v.val, 0)
+					v.val = b[:len(b)-1]
+					return uintptr(unsafe.Pointer(&b[0])), nil
 				default:
-					return 0, fmt.Errorf("syscall() arg must be i64 or str")
+					return 0, fmt.Errorf("syscall() arg must be i64, str or file")
 				}
 			}
 
 			tr := args[0]
-			if tr.typ != tI64 {
+			if _, ok := tr.(*oI64); !ok {
 				return NIL, fmt.Errorf("syscall: first argument must be a syscall number(i64)")
 			}
 
@@ -113,11 +277,178 @@ var builtinFns = map[string]*obj{
 			}
 
 			r1, r2, errno := unix.Syscall(trap, a1, a2, a3)
-			return &obj{typ: tList, list: []*obj{
-				{typ: tI64, ival: int64(r1)},
-				{typ: tI64, ival: int64(r2)},
-				{typ: tI64, ival: int64(errno)},
+			return &oTuple{vals: []obj{
+				&oI64{val: int64(r1)},
+				&oI64{val: int64(r2)},
+				&oI64{val: int64(errno)},
 			}}, nil
 		},
 	},
+	"tuple": tTuple,
+	"type": &oBuiltinFunc{
+		name: "type",
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			if len(args) != 1 {
+				return NIL, fmt.Errorf("argument mismatch to type(): 1 arg required")
+			}
+
+			return typeof(args[0]), nil
+		},
+	},
+}
+
+// builtins and built-in methods which call back functions are registered here to avoid initialization cycle.
+func init() {
+	listMethods["sort"] = listsort
+
+	builtinFns["filter"] = &oBuiltinFunc{
+		name: "filter",
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			if len(args) != 2 {
+				return NIL, fmt.Errorf("argument mismatch to filter(): 2 args required")
+			}
+
+			vals, ok, err := elems(args[1])
+			if err != nil {
+				return NIL, err
+			}
+
+			if !ok {
+				return NIL, fmt.Errorf("filter() second arg must be iterable")
+			}
+
+			l := &oList{vals: []obj{}}
+			for _, v := range vals {
+				o, err := env.call(args[0], v)
+				if err != nil {
+					return NIL, err
+				}
+
+				if o.isTruethy() {
+					l.vals = append(l.vals, v)
+				}
+			}
+
+			return l, nil
+		},
+	}
+
+	builtinFns["map"] = &oBuiltinFunc{
+		name: "map",
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			if len(args) != 2 {
+				return NIL, fmt.Errorf("argument mismatch to map(): 2 args required")
+			}
+
+			vals, ok, err := elems(args[1])
+			if err != nil {
+				return NIL, err
+			}
+
+			if !ok {
+				return NIL, fmt.Errorf("map() second arg must be iterable")
+			}
+
+			l := &oList{vals: make([]obj, len(vals))}
+			for i, v := range vals {
+				o, err := env.call(args[0], v)
+				if err != nil {
+					return NIL, err
+				}
+
+				l.vals[i] = o
+			}
+
+			return l, nil
+		},
+	}
+
+	builtinFns["reduce"] = &oBuiltinFunc{
+		name: "reduce",
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			if len(args) != 2 && len(args) != 3 {
+				return NIL, fmt.Errorf("argument mismatch to reduce(): 2 or 3 args required")
+			}
+
+			vals, ok, err := elems(args[1])
+			if err != nil {
+				return NIL, err
+			}
+
+			if !ok {
+				return NIL, fmt.Errorf("reduce() second arg must be iterable")
+			}
+
+			// without initial value, the first element is used
+			if len(args) == 2 {
+				if len(vals) == 0 {
+					return NIL, fmt.Errorf("reduce() of empty iterable with no initial value")
+				}
+
+				args = append(args, vals[0])
+				vals = vals[1:]
+			}
+
+			acc := args[2]
+			for _, v := range vals {
+				o, err := env.call(args[0], acc, v)
+				if err != nil {
+					return NIL, err
+				}
+
+				acc = o
+			}
+
+			return acc, nil
+		},
+	}
+
+	builtinFns["sorted"] = &oBuiltinFunc{
+		name: "sorted",
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return NIL, fmt.Errorf("argument mismatch to sorted(): 1 or 2 args required")
+			}
+
+			vals, ok, err := elems(args[0])
+			if err != nil {
+				return NIL, err
+			}
+
+			if !ok {
+				return NIL, fmt.Errorf("sorted() first arg must be iterable")
+			}
+
+			var key obj
+			if len(args) == 2 {
+				key = args[1]
+			}
+
+			if err := sortkw(env, "sorted", vals, key, kw); err != nil {
+				return NIL, err
+			}
+
+			return &oList{vals: vals}, nil
+		},
+		kwnames: []string{"key", "reverse"},
+	}
+}
+
+// sprintfargs formats args[1:] according to args[0] for sprintf family.
+func sprintfargs(name string, args []obj) (string, error) {
+	if len(args) < 1 {
+		return "", fmt.Errorf("argument mismatch to %s(): format is required", name)
+	}
+
+	format, ok := args[0].(*oStr)
+	if !ok {
+		return "", fmt.Errorf("%s() format must be str", name)
+	}
+
+	s, err := sprintf(format.String(), args[1:])
+	if err != nil {
+		return "", fmt.Errorf("%s(): %w", name, err)
+	}
+
+	return s, nil
 }
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checker finds the mistakes in a statement before it runs, and reports them as warnings to stderr.
+// Warnings do not stop the execution.
+// The names are resolved with the definitions made by the statements run so far,
+// so a name defined later, or a value which is known only at run time, is not checked.
+type checker struct {
+	env *environment
+	mod *module
+	// locals are the names defined in the function being checked, which shadow the global ones.
+	// nil outside functions.
+	locals map[string]bool
+}
+
+func newchecker(env *environment, mod *module) *checker {
+	return &checker{env: env, mod: mod}
+}
+
+func (c *checker) warn(n node, format string, a ...any) {
+	l := n.token().loc
+	fmt.Fprintf(c.env.stderr, "%s:%d:%d warning: %s\n", l.mod, l.line, l.col, fmt.Sprintf(format, a...))
+}
+
+func (c *checker) checkall(nodes []node) {
+	for _, n := range nodes {
+		c.check(n)
+	}
+}
+
+// check checks the node and the nodes in it.
+func (c *checker) check(n node) {
+	switch n := n.(type) {
+	case *ndAssign:
+		c.checkall(n.left)
+		c.checkall(n.right)
+
+	case *ndIf:
+		c.checkall(n.conds)
+		for _, b := range n.blocks {
+			c.checkall(b)
+		}
+
+	case *ndTry:
+		c.checkall(n.blocks)
+		c.checkall(n.catchblocks)
+
+	case *ndMatch:
+		c.checkmatch(n)
+		c.check(n.target)
+		for _, cs := range n.cases {
+			if cs.guard != nil {
+				c.check(cs.guard)
+			}
+			c.checkall(cs.blocks)
+		}
+
+	case *ndLoop:
+		c.check(n.target)
+		c.checkall(n.blocks)
+
+	case *ndCondLoop:
+		if n.cond != nil {
+			c.check(n.cond)
+		}
+		c.checkall(n.blocks)
+
+	case *ndFunDef:
+		c.checkfunc(n, nil)
+
+	case *ndStructDef:
+		members := c.members(n)
+		for _, fn := range n.fns {
+			c.checkfunc(fn.(*ndFunDef), members)
+		}
+
+	case *ndFuncall:
+		c.checkcall(n)
+		c.check(n.fn)
+		c.checkall(n.args)
+
+	case *ndBinaryOp:
+		c.check(n.left)
+		c.check(n.right)
+
+	case *ndUnaryOp:
+		c.check(n.target)
+
+	case *ndSelector:
+		c.check(n.selector)
+
+	case *ndIndex:
+		c.check(n.target)
+		c.check(n.idx)
+
+	case *ndSlice:
+		c.check(n.target)
+		for _, idx := range []node{n.start, n.end, n.step} {
+			if idx != nil {
+				c.check(idx)
+			}
+		}
+
+	case *ndKwArg:
+		c.check(n.val)
+
+	case *ndSpread:
+		c.check(n.target)
+
+	case *ndFStr:
+		c.checkall(n.parts)
+
+	case *ndFormat:
+		c.check(n.target)
+
+	case *ndList:
+		c.checkall(n.vals)
+
+	case *ndTuple:
+		c.checkall(n.vals)
+
+	case *ndSet:
+		c.checkall(n.vals)
+
+	case *ndDict:
+		c.checkall(n.keys)
+		c.checkall(n.vals)
+
+	case *ndStructInit:
+		c.check(n.values)
+
+	case *ndReturn:
+		if n.val != nil {
+			c.check(n.val)
+		}
+
+	case *ndDel:
+		c.checkall(n.targets)
+	}
+}
+
+// checkfunc checks the function body. members are the fields and methods of the struct if the function is its method,
+// which can be referred without the receiver.
+func (c *checker) checkfunc(n *ndFunDef, members map[string]bool) {
+	// the function does not see the names in the enclosing function
+	locals := map[string]bool{}
+	for name := range members {
+		locals[name] = true
+	}
+
+	if members != nil {
+		if n.recv != "" {
+			locals[n.recv] = true
+		} else {
+			locals["self"] = true
+		}
+	}
+
+	for _, p := range n.params {
+		locals[p.(*ndParam).name] = true
+	}
+
+	definednames(n.blocks, locals)
+
+	outer := c.locals
+	c.locals = locals
+	c.checkall(n.blocks)
+	c.locals = outer
+}
+
+// members returns the names of the fields and methods of the struct, including the ones promoted from the embedded structs.
+func (c *checker) members(n *ndStructDef) map[string]bool {
+	names := map[string]bool{}
+	for _, fn := range n.fns {
+		names[fn.(*ndFunDef).name] = true
+	}
+
+	var embedded func(sd *structdef)
+	embedded = func(sd *structdef) {
+		for _, v := range sd.vars {
+			names[v] = true
+		}
+		for _, m := range sd.defs {
+			names[m.name] = true
+		}
+		for _, esd := range sd.embeds {
+			embedded(esd)
+		}
+	}
+
+	for _, v := range n.vars {
+		vname, ok := v.(*ndIdent)
+		if !ok {
+			continue
+		}
+
+		names[vname.ident] = true
+		if esd, ok := c.env.getstruct(c.mod, vname.ident); ok {
+			embedded(esd)
+		}
+	}
+
+	return names
+}
+
+// definednames adds the names which the statements define in the function scope to names.
+func definednames(stmts []node, names map[string]bool) {
+	for _, stmt := range stmts {
+		switch n := stmt.(type) {
+		case *ndAssign:
+			for _, l := range n.left {
+				if id, ok := l.(*ndIdent); ok {
+					names[id.ident] = true
+				}
+			}
+
+		case *ndFunDef:
+			names[n.name] = true
+
+		case *ndIf:
+			for _, b := range n.blocks {
+				definednames(b, names)
+			}
+
+		case *ndTry:
+			if id, ok := n.errname.(*ndIdent); ok {
+				names[id.ident] = true
+			}
+			definednames(n.blocks, names)
+			definednames(n.catchblocks, names)
+
+		case *ndMatch:
+			for _, cs := range n.cases {
+				patternnames(cs.pattern, names)
+				definednames(cs.blocks, names)
+			}
+
+		case *ndLoop:
+			for _, v := range []node{n.cnt, n.elem} {
+				if id, ok := v.(*ndIdent); ok {
+					names[id.ident] = true
+				}
+			}
+			definednames(n.blocks, names)
+
+		case *ndCondLoop:
+			definednames(n.blocks, names)
+		}
+	}
+}
+
+// patternnames adds the names which the match pattern can capture to names.
+// It may add the names which are not captured, such as the field names of the struct pattern,
+// but they only make the check skip the names.
+func patternnames(pattern node, names map[string]bool) {
+	switch p := pattern.(type) {
+	case *ndIdent:
+		names[p.ident] = true
+
+	case *ndFuncall:
+		for _, arg := range p.args {
+			patternnames(arg, names)
+		}
+
+	case *ndList:
+		for _, v := range p.vals {
+			patternnames(v, names)
+		}
+
+	case *ndTuple:
+		for _, v := range p.vals {
+			patternnames(v, names)
+		}
+
+	case *ndDict:
+		for _, v := range p.vals {
+			patternnames(v, names)
+		}
+
+	case *ndStructInit:
+		patternnames(p.values, names)
+	}
+}
+
+// checkcall warns when a struct literal is passed to the param annotated with the interface which the struct does not implement.
+// Only the calls in functions are checked. The calls outside functions run right away,
+// and the mismatch is reported as the runtime error.
+func (c *checker) checkcall(n *ndFuncall) {
+	if c.locals == nil {
+		return
+	}
+
+	name, ok := n.fn.(*ndIdent)
+	if !ok || c.locals[name.ident] {
+		return
+	}
+
+	o, ok := c.env.getobj(c.mod, name.ident)
+	if !ok {
+		return
+	}
+
+	f, ok := o.(*oFunc)
+	if !ok {
+		return
+	}
+
+	pos := 0
+	for _, arg := range n.args {
+		var p *param
+		switch a := arg.(type) {
+		case *ndSpread:
+			// the params after the spread args are unknown
+			return
+
+		case *ndKwArg:
+			for _, fp := range f.params {
+				if fp.name == a.name && !fp.variadic && !fp.kwvariadic {
+					p = fp
+				}
+			}
+			arg = a.val
+
+		default:
+			if pos < len(f.params) && !f.params[pos].variadic && !f.params[pos].kwvariadic {
+				p = f.params[pos]
+			}
+			pos++
+		}
+
+		if p == nil || p.iface == nil {
+			continue
+		}
+
+		si, ok := arg.(*ndStructInit)
+		if !ok {
+			continue
+		}
+
+		sname, ok := si.name.(*ndIdent)
+		if !ok {
+			continue
+		}
+
+		sd, ok := c.env.getstruct(c.mod, sname.ident)
+		if !ok {
+			continue
+		}
+
+		if err := p.iface.checkdef(sd); err != nil {
+			c.warn(sname, "arg %s to %s(): %s", p.name, f.name, err)
+		}
+	}
+}
+
+// checkmatch warns when the match over the enum does not cover all the variants.
+// It is checked only when every pattern is a variant of the same enum, such as Shape.Empty or Shape.Rect(w, h).
+// A case with guard, or with a pattern which does not match every payload, does not cover the variant.
+func (c *checker) checkmatch(n *ndMatch) {
+	var enum *oEnum
+	covered := map[*oVariant]bool{}
+	for _, cs := range n.cases {
+		v, full := c.variantpattern(cs.pattern)
+		if v == nil || (enum != nil && v.enum != enum) {
+			return
+		}
+
+		enum = v.enum
+		if full && cs.guard == nil {
+			covered[v] = true
+		}
+	}
+
+	if enum == nil {
+		return
+	}
+
+	missing := []string{}
+	for _, v := range enum.variants {
+		if !covered[v] {
+			missing = append(missing, v.name)
+		}
+	}
+
+	if len(missing) > 0 {
+		c.warn(n, "match over %s is not exhaustive: missing %s", enum.name, strings.Join(missing, ", "))
+	}
+}
+
+// variantpattern returns the variant if the pattern is "Enum.Variant" or "Enum.Variant(...)".
+// full is true if the pattern matches every value of the variant.
+func (c *checker) variantpattern(pattern node) (v *oVariant, full bool) {
+	sel := pattern
+	var args []node
+	call, iscall := pattern.(*ndFuncall)
+	if iscall {
+		sel = call.fn
+		args = call.args
+	}
+
+	s, ok := sel.(*ndSelector)
+	if !ok {
+		return nil, false
+	}
+
+	ename, ok := s.selector.(*ndIdent)
+	if !ok {
+		return nil, false
+	}
+
+	vname, ok := s.target.(*ndIdent)
+	if !ok {
+		return nil, false
+	}
+
+	o, ok := c.env.getobj(c.mod, ename.ident)
+	if !ok {
+		return nil, false
+	}
+
+	e, ok := o.(*oEnum)
+	if !ok {
+		return nil, false
+	}
+
+	v, ok = e.variant(vname.ident)
+	if !ok {
+		return nil, false
+	}
+
+	// a variant with payload matches only when it is destructured
+	if !iscall {
+		return v, len(v.params) == 0
+	}
+
+	// the wrong number of fields is reported when it runs
+	if len(args) != len(v.params) {
+		return nil, false
+	}
+
+	for _, arg := range args {
+		if _, ok := arg.(*ndIdent); !ok {
+			return v, false
+		}
+	}
+
+	return v, true
+}
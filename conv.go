@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// built-in types. Calling them converts the argument into the type.
+var (
+	tI64   = &oType{name: "i64", conv: toi64}
+	tF64   = &oType{name: "f64", conv: tof64}
+	tStr   = &oType{name: "str", conv: tostr}
+	tBool  = &oType{name: "bool", conv: tobool}
+	tList  = &oType{name: "list", conv: tolist}
+	tTuple = &oType{name: "tuple", conv: totuple}
+	tDict  = &oType{name: "dict", conv: todict}
+	tSet   = &oType{name: "set", conv: toset}
+	tFile  = &oType{name: "file", conv: tofile}
+	tNil   = &oType{name: "nil"}
+)
+
+// typeof returns the type of the obj.
+// bigint is the same type as i64 because it is just an i64 which does not fit in 64 bits.
+func typeof(o obj) *oType {
+	switch v := o.(type) {
+	case *oI64, *oBigInt:
+		return tI64
+	case *oF64:
+		return tF64
+	case *oStr:
+		return tStr
+	case *oBool:
+		return tBool
+	case *oList:
+		return tList
+	case *oTuple:
+		return tTuple
+	case *oDict:
+		return tDict
+	case *oSet:
+		return tSet
+	case *oFile:
+		return tFile
+	case *oNil:
+		return tNil
+	case *oStruct:
+		return &oType{name: v.def.name, def: v.def}
+	}
+
+	return &oType{name: o.typename()}
+}
+
+// isinstance reports if the obj is the type. t can be a list of types to check any of them matches.
+func isinstance(o obj, t obj) (bool, error) {
+	switch tt := t.(type) {
+	case *oType:
+		return typeof(o).equals(tt)
+
+	case *oInterface:
+		return tt.implementedby(o), nil
+
+	case *oEnum:
+		ev, ok := o.(*oEnumValue)
+		return ok && ev.variant.enum == tt, nil
+
+	case *oList:
+		for _, v := range tt.vals {
+			ok, err := isinstance(o, v)
+			if err != nil {
+				return false, err
+			}
+
+			if ok {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	return false, fmt.Errorf("type, interface, enum or list of them is expected but got %s", t.typename())
+}
+
+// toi64 is i64(x) or int(x). When x is str, the base can be given as the second argument.
+// base 0 means the base is determined by the prefix "0x", "0o" or "0b".
+func toi64(args ...obj) (obj, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, fmt.Errorf("argument mismatch to int(): 1 or 2 args required")
+	}
+
+	if len(args) == 2 {
+		if _, ok := args[0].(*oStr); !ok {
+			return nil, fmt.Errorf("int() with base is only for str")
+		}
+	}
+
+	switch v := args[0].(type) {
+	case *oI64, *oBigInt:
+		return v, nil
+
+	case *oBool:
+		if v.val {
+			return &oI64{val: 1}, nil
+		}
+		return &oI64{val: 0}, nil
+
+	case *oF64:
+		if math.IsInf(v.val, 0) || math.IsNaN(v.val) {
+			return nil, fmt.Errorf("cannot convert %s to i64", v)
+		}
+
+		if v.val >= math.MinInt64 && v.val < math.MaxInt64 {
+			return &oI64{val: int64(v.val)}, nil
+		}
+
+		i, _ := big.NewFloat(v.val).Int(nil)
+		return newint(i), nil
+
+	case *oStr:
+		base := 10
+		if len(args) == 2 {
+			b, ok := args[1].(*oI64)
+			if !ok || (b.val != 0 && (b.val < 2 || b.val > 36)) {
+				return nil, fmt.Errorf("int() base must be 0 or between 2 and 36")
+			}
+			base = int(b.val)
+		}
+
+		s := strings.TrimSpace(v.String())
+		i, ok := new(big.Int).SetString(s, base)
+		if !ok {
+			return nil, fmt.Errorf("invalid literal for int() with base %d: %q", base, v.String())
+		}
+
+		return newint(i), nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to i64", args[0].typename())
+}
+
+// tof64 is f64(x) or float(x).
+func tof64(args ...obj) (obj, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("argument mismatch to float(): 1 arg required")
+	}
+
+	switch v := args[0].(type) {
+	case *oF64:
+		return v, nil
+
+	case *oI64:
+		return &oF64{val: float64(v.val)}, nil
+
+	case *oBigInt:
+		return &oF64{val: bigtof64(v.val)}, nil
+
+	case *oBool:
+		if v.val {
+			return &oF64{val: 1}, nil
+		}
+		return &oF64{val: 0}, nil
+
+	case *oStr:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v.String()), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid literal for float(): %q", v.String())
+		}
+
+		return &oF64{val: f}, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to f64", args[0].typename())
+}
+
+// tostr is str(x). It is the same string as print(x).
+func tostr(args ...obj) (obj, error) {
+	switch len(args) {
+	case 0:
+		return newstr(""), nil
+	case 1:
+		s, err := strof(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return newstr(s), nil
+	}
+
+	return nil, fmt.Errorf("argument mismatch to str(): 0 or 1 arg required")
+}
+
+// tobool is bool(x). It is the same as x is used as a condition.
+func tobool(args ...obj) (obj, error) {
+	switch len(args) {
+	case 0:
+		return FALSE, nil
+	case 1:
+		return newbool(args[0].isTruethy()), nil
+	}
+
+	return nil, fmt.Errorf("argument mismatch to bool(): 0 or 1 arg required")
+}
+
+// tolist is list(x). It collects the elements of iterable x.
+func tolist(args ...obj) (obj, error) {
+	switch len(args) {
+	case 0:
+		return &oList{}, nil
+	case 1:
+		vals, ok, err := elems(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %s to list", args[0].typename())
+		}
+
+		return &oList{vals: vals}, nil
+	}
+
+	return nil, fmt.Errorf("argument mismatch to list(): 0 or 1 arg required")
+}
+
+// totuple is tuple(x). It collects the elements of iterable x.
+func totuple(args ...obj) (obj, error) {
+	switch len(args) {
+	case 0:
+		return &oTuple{}, nil
+	case 1:
+		vals, ok, err := elems(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %s to tuple", args[0].typename())
+		}
+
+		return &oTuple{vals: vals}, nil
+	}
+
+	return nil, fmt.Errorf("argument mismatch to tuple(): 0 or 1 arg required")
+}
+
+// elems collects the elements of the iterable obj. ok is false if the obj is not iterable.
+func elems(o obj) (vals []obj, ok bool, err error) {
+	if !o.isIterable() {
+		return nil, false, nil
+	}
+
+	it, err := o.iterator()
+	if err != nil {
+		return nil, true, err
+	}
+
+	vals = []obj{}
+	for it.hasnext() {
+		e, _ := it.next() // the index is not used
+		vals = append(vals, e)
+	}
+
+	return vals, true, nil
+}
+
+// todict is dict(x). It copies the dict x.
+func todict(args ...obj) (obj, error) {
+	switch len(args) {
+	case 0:
+		return &oDict{dict: newdict()}, nil
+	case 1:
+		d, ok := args[0].(*oDict)
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %s to dict", args[0].typename())
+		}
+
+		return &oDict{dict: d.dict.clone()}, nil
+	}
+
+	return nil, fmt.Errorf("argument mismatch to dict(): 0 or 1 arg required")
+}
+
+// toset is set(x). It collects the elements of iterable x.
+func toset(args ...obj) (obj, error) {
+	switch len(args) {
+	case 0:
+		return newset(nil)
+	case 1:
+		vals, ok, err := elems(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %s to set", args[0].typename())
+		}
+
+		return newset(vals)
+	}
+
+	return nil, fmt.Errorf("argument mismatch to set(): 0 or 1 arg required")
+}
+
+// tofile is file(fd, name). It wraps an open file descriptor. The name defaults to the descriptor number.
+func tofile(args ...obj) (obj, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, fmt.Errorf("argument mismatch to file(): 1 or 2 args required")
+	}
+
+	fd, ok := args[0].(*oI64)
+	if !ok || fd.val < 0 {
+		return nil, fmt.Errorf("file() first arg must be a file descriptor(non-negative i64)")
+	}
+
+	name := strconv.FormatInt(fd.val, 10)
+	if len(args) == 2 {
+		s, ok := args[1].(*oStr)
+		if !ok {
+			return nil, fmt.Errorf("file() second arg must be str but got %s", args[1].typename())
+		}
+		name = s.String()
+	}
+
+	return &oFile{fd: int(fd.val), name: name}, nil
+}
@@ -7,113 +7,202 @@ import (
 
 // dict is an ordered dictionary implementation.
 // In shiba dict is always ordered.
+// Keys are looked up by hash() first, then compared by equals() as different keys can have the same hash.
 type dict struct {
-	kv   map[objkey]*obj          // objkey to value
-	kk   map[objkey]*obj          // objkey to key
-	keys *list.List               // objkey list
-	ke   map[objkey]*list.Element // objkey to list element. this is needed to delete in O(1)
+	entries *list.List                 // *dictentry in the insertion order
+	buckets map[uint64][]*list.Element // hash to the entries which have the hash
+}
+
+type dictentry struct {
+	hash uint64
+	k, v obj
 }
 
 func newdict() *dict {
 	return &dict{
-		kv:   map[objkey]*obj{},
-		kk:   map[objkey]*obj{},
-		keys: list.New(),
-		ke:   map[objkey]*list.Element{},
+		entries: list.New(),
+		buckets: map[uint64][]*list.Element{},
 	}
 }
 
-func (d *dict) equals(x *dict) bool {
+// equals reports if the both dicts have the equal entries in the same order.
+func (d *dict) equals(x *dict) (bool, error) {
 	if d.size() != x.size() {
-		return false
+		return false, nil
+	}
+
+	for e, xe := d.entries.Front(), x.entries.Front(); e != nil; e, xe = e.Next(), xe.Next() {
+		de, xde := e.Value.(*dictentry), xe.Value.(*dictentry)
+		if de.hash != xde.hash {
+			return false, nil
+		}
+
+		if eq, err := de.k.equals(xde.k); err != nil || !eq {
+			return false, err
+		}
+
+		if eq, err := de.v.equals(xde.v); err != nil || !eq {
+			return false, err
+		}
 	}
 
-	dkeys := []objkey{}
-	for e := d.keys.Front(); e != nil; e = e.Next() {
-		dkeys = append(dkeys, e.Value.(objkey))
+	return true, nil
+}
+
+// clone returns the deep copy of the dict. Keys are cloned too, but their hashes are reused.
+func (d *dict) clone() *dict {
+	cloned := newdict()
+	for e := d.entries.Front(); e != nil; e = e.Next() {
+		de := e.Value.(*dictentry)
+		cloned.push(&dictentry{hash: de.hash, k: de.k.clone(), v: de.v.clone()})
 	}
 
-	xkeys := []objkey{}
-	for e := x.keys.Front(); e != nil; e = e.Next() {
-		xkeys = append(xkeys, e.Value.(objkey))
+	return cloned
+}
+
+// copy returns the shallow copy of the dict.
+func (d *dict) copy() *dict {
+	copied := newdict()
+	for e := d.entries.Front(); e != nil; e = e.Next() {
+		de := e.Value.(*dictentry)
+		copied.push(&dictentry{hash: de.hash, k: de.k, v: de.v})
 	}
 
-	for i := range dkeys {
-		if dkeys[i] != xkeys[i] {
-			return false
+	return copied
+}
+
+// update sets every entry in x to the dict. The order of the existing keys is kept.
+func (d *dict) update(x *dict) error {
+	for e := x.entries.Front(); e != nil; e = e.Next() {
+		xe := e.Value.(*dictentry)
+		found, err := d.lookup(xe.hash, xe.k)
+		if err != nil {
+			return err
 		}
 
-		if !d.kv[dkeys[i]].equals(x.kv[xkeys[i]]) {
-			return false
+		if found != nil {
+			found.Value.(*dictentry).v = xe.v
+			continue
 		}
+
+		d.push(&dictentry{hash: xe.hash, k: xe.k, v: xe.v})
 	}
 
-	return true
+	return nil
 }
 
-func (d *dict) clone() *dict {
-	cloned := newdict()
+// push appends the entry which is known not to be in the dict.
+func (d *dict) push(de *dictentry) {
+	e := d.entries.PushBack(de)
+	d.buckets[de.hash] = append(d.buckets[de.hash], e)
+}
+
+// lookup returns the entry of the key k which has hash h, or nil if not found.
+func (d *dict) lookup(h uint64, k obj) (*list.Element, error) {
+	for _, e := range d.buckets[h] {
+		eq, err := e.Value.(*dictentry).k.equals(k)
+		if err != nil {
+			return nil, err
+		}
 
-	for e := d.keys.Front(); e != nil; e = e.Next() {
-		key := e.Value.(objkey)
-		cloned.set(d.kk[key].clone(), d.kv[key].clone())
+		if eq {
+			return e, nil
+		}
 	}
 
-	return cloned
+	return nil, nil
+}
+
+// find returns the entry of the key k. It fails if k is not hashable.
+func (d *dict) find(k obj) (*list.Element, uint64, error) {
+	h, err := k.hash()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	e, err := d.lookup(h, k)
+	return e, h, err
 }
 
-func (d *dict) set(k, v *obj) {
-	key := k.toObjKey()
-	_, ok := d.kv[key]
-	if ok {
-		d.kv[key] = v
-		return
+func (d *dict) set(k, v obj) error {
+	e, h, err := d.find(k)
+	if err != nil {
+		return err
 	}
 
-	d.kv[key] = v
-	d.kk[key] = k
-	e := d.keys.PushBack(key)
-	d.ke[key] = e
+	if e != nil {
+		e.Value.(*dictentry).v = v
+		return nil
+	}
+
+	d.push(&dictentry{hash: h, k: k, v: v})
+	return nil
 }
 
-func (d *dict) get(k *obj) (*obj, bool) {
-	key := k.toObjKey()
-	o, ok := d.kv[key]
-	return o, ok
+func (d *dict) get(k obj) (obj, bool, error) {
+	e, _, err := d.find(k)
+	if err != nil || e == nil {
+		return nil, false, err
+	}
+
+	return e.Value.(*dictentry).v, true, nil
 }
 
-func (d *dict) del(k *obj) bool {
-	key := k.toObjKey()
-	o, ok := d.ke[key]
-	if !ok {
-		return false
+func (d *dict) del(k obj) (bool, error) {
+	e, h, err := d.find(k)
+	if err != nil || e == nil {
+		return false, err
+	}
+
+	bucket := d.buckets[h]
+	for i := range bucket {
+		if bucket[i] == e {
+			bucket = append(bucket[:i], bucket[i+1:]...)
+			break
+		}
+	}
+
+	if len(bucket) == 0 {
+		delete(d.buckets, h)
+	} else {
+		d.buckets[h] = bucket
 	}
 
-	d.keys.Remove(o)
-	delete(d.ke, key)
-	delete(d.kk, key)
-	delete(d.kv, key)
-	return true
+	d.entries.Remove(e)
+	return true, nil
 }
 
 func (d *dict) size() int {
-	return d.keys.Len()
+	return d.entries.Len()
+}
+
+// keys returns the keys in the insertion order.
+func (d *dict) keys() []obj {
+	keys := make([]obj, 0, d.size())
+	for e := d.entries.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(*dictentry).k)
+	}
+	return keys
+}
+
+// vals returns the values in the insertion order.
+func (d *dict) vals() []obj {
+	vals := make([]obj, 0, d.size())
+	for e := d.entries.Front(); e != nil; e = e.Next() {
+		vals = append(vals, e.Value.(*dictentry).v)
+	}
+	return vals
 }
 
 func (d *dict) String() string {
 	sb := strings.Builder{}
 	sb.WriteString("{")
-	e := d.keys.Front()
-	for {
-		if e == nil {
-			break
-		}
-		key := e.Value.(objkey)
-		sb.WriteString(strings.Split(string(key), "_")[1])
+	for e := d.entries.Front(); e != nil; e = e.Next() {
+		de := e.Value.(*dictentry)
+		sb.WriteString(de.k.String())
 		sb.WriteString(": ")
-		sb.WriteString(d.kv[key].String())
-		e = e.Next()
-		if e != nil {
+		sb.WriteString(de.v.String())
+		if e.Next() != nil {
 			sb.WriteString(", ")
 		}
 	}
@@ -1,8 +1,8 @@
-package main_test
+package main
 
 import (
+	"bytes"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -52,7 +52,7 @@ func TestOutput(t *testing.T) {
 				print(a)
 			`),
 			out: d(`
-				5.000000
+				5.0
 			`),
 		},
 		"arithmetic5": {
@@ -61,7 +61,7 @@ func TestOutput(t *testing.T) {
 				print(a)
 			`),
 			out: d(`
-				50.000000
+				50.0
 			`),
 		},
 		"arithmetic6": {
@@ -106,6 +106,81 @@ func TestOutput(t *testing.T) {
 				-19
 			`),
 		},
+		"bigint1": {
+			content: d(`
+				a = 9223372036854775807
+				print(a + 1)
+				print(a * a)
+				print(-a - 2)
+				print(-9223372036854775808 / -1)
+			`),
+			out: d(`
+				9223372036854775808
+				85070591730234615847396907784232501249
+				-9223372036854775809
+				9223372036854775808
+			`),
+		},
+		"bigint2": {
+			content: d(`
+				a = 123456789012345678901234567890
+				print(a * 2, a / 10, a % 7)
+				print(1 << 100, (1 << 100) >> 99)
+				print(^(1 << 64), (1 << 64) | 1, (1 << 64) & 3)
+				print((1 << 64) == (1 << 64), (1 << 64) - (1 << 64) == 0, (1 << 64) > 1)
+			`),
+			out: d(`
+				246913578024691357802469135780 12345678901234567890123456789 0
+				1267650600228229401496703205376 2
+				-18446744073709551617 18446744073709551617 0
+				true true true
+			`),
+		},
+		"number1": {
+			content: d(`
+				print(0x1F, 0X1f, 0o777, 0b1010, 1_000_000, 0x_FF)
+				print(1.5e-3, .5, 1e3, 2E+2, 1_0.2_5)
+				print(0xFFFFFFFFFFFFFFFFFF)
+			`),
+			out: d(`
+				31 31 511 10 1000000 255
+				0.0015 0.5 1000.0 200.0 10.25
+				4722366482869645213695
+			`),
+		},
+		"number2": {
+			content: d(`
+				a = 0b102
+			`),
+			out: d(`
+				$$filename:1:9 invalid digit '2' in binary literal
+			`),
+		},
+		"number3": {
+			content: d(`
+				a = 1
+				b = 1e+
+			`),
+			out: d(`
+				$$filename:2:8 exponent has no digits
+			`),
+		},
+		"number4": {
+			content: d(`
+				a = 1__000
+			`),
+			out: d(`
+				$$filename:1:6 '_' must separate successive digits
+			`),
+		},
+		"number5": {
+			content: d(`
+				a = 0777
+			`),
+			out: d(`
+				$$filename:1:5 leading zeros in decimal integer literal are not permitted; use 0o prefix for octal
+			`),
+		},
 		"concat1": {
 			content: d(`
 				a = "xxx"
@@ -134,6 +209,572 @@ func TestOutput(t *testing.T) {
 				xxxxxxxxx
 			`),
 		},
+		"str1": {
+			content: d(`
+				print("a\tb\\c\"d'e")
+				print('single "q" \'x\'')
+				print("\x41\u{3042}", len("\u{3042}"))
+				print(r"raw\n\t\"")
+			`),
+			out: "a\tb\\c\"d'e\n" +
+				"single \"q\" 'x'\n" +
+				"A\u3042 1\n" +
+				"raw\\n\\t\\\"\n",
+		},
+		"str2": {
+			content: d(`
+				a = """line1
+				  "line2"
+				line3"""
+				print(a)
+				print(b)
+			`),
+			out: d(`
+				line1
+				  "line2"
+				line3
+				$$filename:5:7 b is undefined
+			`),
+		},
+		"str3": {
+			content: d(`
+				a = "abc\q"
+			`),
+			out: d(`
+				$$filename:1:9 unknown escape sequence \q
+			`),
+		},
+		"str4": {
+			content: d(`
+				a = "abc
+			`),
+			out: d(`
+				$$filename:1:9 newline in string
+			`),
+		},
+		"fstr1": {
+			content: d(`
+				struct User {
+					Name
+				}
+				u = User{Name: "alice"}
+				age = 20
+				print(f"user {u.Name} is {age + 1} years old")
+				print(f"{{age}} {[1, 2][1]} {{{age}}}")
+				print(f'{"k"} { {"k": 1}["k"]}')
+			`),
+			out: d(`
+				user alice is 21 years old
+				{age} 2 {20}
+				k 1
+			`),
+		},
+		"fstr2": {
+			content: d(`
+				n = 42
+				print(f"[{n:5}] [{n:<5}] [{n:^6}] [{n:05}] [{n:x}] [{255:#X}] [{n:+}] [{n:b}]")
+				print(f"[{3.14159:.2f}] [{3.14159:8.3f}] [{2.5:e}] [{0.25:.1%}] [{3.14159:.2}]")
+				print(f"[{"abc":>6}] [{"abcdef":.3}] [{"x":*^5}] [{1234567.891:,.2f}]")
+			`),
+			out: d(`
+				[   42] [42   ] [  42  ] [00042] [2a] [0XFF] [+42] [101010]
+				[3.14] [   3.142] [2.500000e+00] [25.0%] [3.1]
+				[   abc] [abc] [**x**] [1,234,567.89]
+			`),
+		},
+		"fstr3": {
+			content: d(`
+				a = 1
+				print(f"a is {a + b}")
+			`),
+			out: d(`
+				$$filename:2:19 b is undefined
+			`),
+		},
+		"fstr4": {
+			content: d(`
+				print(f"a is {a:q}")
+			`),
+			out: d(`
+				$$filename:1:17 format spec "q": unknown format type 'q'
+			`),
+		},
+		"fstr5": {
+			content: d(`
+				print(f"a is {}")
+			`),
+			out: d(`
+				$$filename:1:14 empty expression is not allowed in f-string
+			`),
+		},
+		"fstr6": {
+			content: d(`
+				n = 1
+				print(f"{n:99999999999999999999}")
+			`),
+			out: d(`
+				$$filename:2:12 format spec "99999999999999999999": width is too large: 99999999999999999999
+			`),
+		},
+		"printf1": {
+			content: d(`
+				printf("%d|%5d|%-5d|%05d|%+d|%x|%#X|%o|%b|%%\n", 42, 42, 42, -42, 42, 255, 255, 8, 5)
+				printf("%s|%6s|%-6s|%.2s|%q|%t|%x\n", "abc", "abc", "abc", "abc", "a\"b", true, "hi")
+				printf("%.3f|%8.2f|%e|%g|%v|%*d|%.2v\n", 3.14159, 2.5, 123456.789, 0.0001, 0.1 + 0.2, 4, 7, 3.14159)
+			`),
+			out: d(`
+				42|   42|42   |-0042|+42|ff|0XFF|10|101|%
+				abc|   abc|abc   |ab|"a\"b"|true|6869
+				3.142|    2.50|1.234568e+05|0.0001|0.30000000000000004|   7|3.1
+			`),
+		},
+		"printf2": {
+			content: d(`
+				struct User {
+					Name
+					Tags
+				}
+				u = User{Name: "alice", Tags: {"k": [1, "v"]}}
+				s = sprintf("%v / %+v", u, u)
+				print(s)
+				print(sprintf("%v %+v", [1, "1"], [1, "1"]))
+			`),
+			out: d(`
+				User{Name:alice, Tags:{k: [1, v]}} / User{Name:"alice", Tags:{"k": [1, "v"]}}
+				[1, 1] [1, "1"]
+			`),
+		},
+		"printf3": {
+			content: d(`
+				import os
+				fprintf(os.Stdout, "%s %d\n", "out", 1)
+				fprintf(os.Stderr, "%s %d\n", "err", 2)
+			`),
+			out: d(`
+				out 1
+				err 2
+			`),
+		},
+		"printf4": {
+			content: d(`
+				s = sprintf("%d", "a")
+			`),
+			out: d(`
+				$$filename:1:12 sprintf(): %d is invalid for str
+			`),
+		},
+		"printf5": {
+			content: d(`
+				printf("%d %d\n", 1)
+			`),
+			out: d(`
+				$$filename:1:7 printf(): missing argument for %d
+			`),
+		},
+		"printf6": {
+			content: d(`
+				import os
+				print(os.Stdout, type(os.Stderr), isinstance(os.Stdin, file))
+				fprintf(file(1), "%d\n", 3)
+				fprintf(1, "x")
+			`),
+			out: d(`
+				<file stdout> file true
+				3
+				$$filename:4:8 fprintf() first arg must be file but got i64
+			`),
+		},
+		"printf7": {
+			content: d(`
+				printf("%*d\n", 9223372036854775807, 1)
+			`),
+			out: d(`
+				$$filename:1:7 printf(): '*' in format is too large: 9223372036854775807
+			`),
+		},
+		"printf8": {
+			content: d(`
+				printf("%.99999999999999999999f\n", 1.0)
+			`),
+			out: d(`
+				$$filename:1:7 printf(): width or precision in format is too large: 99999999999999999999
+			`),
+		},
+		"float1": {
+			content: d(`
+				print(1.0, 0.1 + 0.2, 1 / 3.0, 1e16, 1e-5, 123.456, -2.5)
+				print(f"{2.0} {1e100}")
+			`),
+			out: d(`
+				1.0 0.30000000000000004 0.3333333333333333 1e+16 1e-05 123.456 -2.5
+				2.0 1e+100
+			`),
+		},
+		"conv1": {
+			content: d(`
+				print(int("42") + 1, int(-3.9), int("ff", 16), float("1e3"), str(1.5) + "x", bool([]))
+				print(type(1), type(1 << 80), type(1.5), type("s"), type([1]), type({}), type(print))
+				print(type(1) == int, type(1 << 80) == i64, type(1.0) == float, type(1) == str)
+			`),
+			out: d(`
+				43 -3 255 1000.0 1.5x false
+				i64 i64 f64 str list dict builtinfunc
+				true true true false
+			`),
+		},
+		"conv2": {
+			content: d(`
+				struct Person {
+					Name
+				}
+				p = Person{Name: "a"}
+				print(type(p), type(p) == Person, isinstance(p, Person), isinstance(1, [str, int]), isinstance("1", int))
+			`),
+			out: d(`
+				Person true true true false
+			`),
+		},
+		"conv3": {
+			content: d(`
+				a = float("x")
+			`),
+			out: d(`
+				$$filename:1:10 invalid literal for float(): "x"
+			`),
+		},
+		"try1": {
+			content: d(`
+				try {
+					a = int("x")
+					print("unreachable")
+				} catch e {
+					print("caught:", e)
+				}
+
+				def f(s) {
+					try {
+						return int(s)
+					} catch {
+						return -1
+					}
+				}
+				print(f("1"), f("a"))
+
+				try {
+					exit(3)
+				} catch {
+					print("exit is not caught")
+				}
+			`),
+			out: d(`
+				caught: invalid literal for int() with base 10: "x"
+				1 -1
+			`),
+		},
+		"nil1": {
+			content: d(`
+				a = nil
+				print(a, a == nil, nil == nil, 1 == nil, 0 != nil, type(nil))
+				def f() {}
+				print(f() == nil, [nil, 1], {nil: 1}[nil])
+			`),
+			out: d(`
+				nil true true false true nil
+				true [nil, 1] 1
+			`),
+		},
+		"nil2": {
+			content: d(`
+				struct Person {
+					Name
+					Friend
+					def Hello() {
+						return "hello " + Name
+					}
+				}
+				a = nil
+				print(a?.Name, a?.Hello(), a?.Friend?.Name)
+				p = Person{Name: "alice", Friend: nil}
+				print(p?.Name, p?.Hello(), p.Friend?.Name)
+			`),
+			out: d(`
+				nil nil nil
+				alice hello alice nil
+			`),
+		},
+		"nil3": {
+			content: d(`
+				def boom() {
+					print("evaluated")
+					return 2
+				}
+				a = nil
+				print(a ?? "default", 0 ?? 1, false ?? true, nil ?? nil ?? 3)
+				print(1 ?? boom(), a ?? boom())
+			`),
+			out: d(`
+				default 0 false 3
+				evaluated
+				1 2
+			`),
+		},
+		"nil4": {
+			content: d(`
+				a = nil
+				print(a.Name)
+			`),
+			out: d(`
+				$$filename:2:8 selector nil is not a module or struct
+			`),
+		},
+		"strmethod1": {
+			content: d(`
+				s = " a,b,,c "
+				print(s.trim().split(","), "a b  c".split(), ", ".join(["x", "y"]), "-".join("abc"))
+				print("hello".startswith("he"), "hello".endswith("x"), "hello".contains("ll"), "héllo".find("l"), "hello".find("z"))
+				print("aaa".replace("a", "b"), "Hi".upper(), "Hi".lower(), "ab".repeat(2), "a\nb\n".lines(), "hé".runes())
+				upper = "abc".upper
+				print(upper(), "key=value".split("=")[1].upper())
+			`),
+			out: d(`
+				[a, b, , c] [a, b, c] x, y a-b-c
+				true false true 2 -1
+				bbb HI hi abab [a, b] [104, 233]
+				ABC VALUE
+			`),
+		},
+		"strmethod2": {
+			content: d(`
+				"abc".reverse()
+			`),
+			out: d(`
+				$$filename:1:6 unknown method reverse of str
+			`),
+		},
+		"strmethod3": {
+			content: d(`
+				"abc".index("z")
+			`),
+			out: d(`
+				$$filename:1:12 substring "z" is not found
+			`),
+		},
+		"strmethod4": {
+			content: d(`
+				"ab".repeat(4611686018427387904)
+			`),
+			out: d(`
+				$$filename:1:12 repeated length is too large
+			`),
+		},
+		"strmethod5": {
+			content: d(`
+				print("ab" * 9223372036854775807)
+			`),
+			out: d(`
+				$$filename:1:12 repeated length is too large
+			`),
+		},
+		"listmethod1": {
+			content: d(`
+				l = [3, 1, 2]
+				l.append(4)
+				print(l.pop(), l.pop(0), l)
+				l.insert(1, 9)
+				l.sort()
+				print(l, l.index(9), l.contains(2))
+				l.reverse()
+				l.remove(9)
+				print(l)
+			`),
+			out: d(`
+				4 3 [1, 2]
+				[1, 2, 9] 2 true
+				[2, 1]
+			`),
+		},
+		"listmethod2": {
+			content: d(`
+				def f(x) {
+					x.append("in f")
+					print(x)
+				}
+
+				l = [1]
+				l2 = l
+				l2.append(2)
+				f(l)
+				print(l, l2)
+			`),
+			out: d(`
+				[1, 2, in f]
+				[1, 2] [1, 2]
+			`),
+		},
+		"listmethod3": {
+			content: d(`
+				def double(x) {
+					return x * 2
+				}
+				def iseven(x) {
+					return x % 2 == 0
+				}
+				def add(acc, x) {
+					return acc + x
+				}
+				def neg(x) {
+					return -x
+				}
+				print(map(double, [1, 2, 3]), filter(iseven, [1, 2, 3, 4]), reduce(add, [1, 2, 3]), reduce(add, [], 10))
+				print(sorted([3, 1, 2]), sorted(["b", "c", "a"]), sorted([3, 1, 2], neg), sorted(["ccc", "a", "bb"], len))
+			`),
+			out: d(`
+				[2, 4, 6] [2, 4] 6 10
+				[1, 2, 3] [a, b, c] [3, 2, 1] [a, bb, ccc]
+			`),
+		},
+		"listmethod4": {
+			content: d(`
+				def f(x) {
+					return x + "a"
+				}
+				map(f, [1])
+			`),
+			out: d(`
+				$$filename:2:11 cannot compute: 1 + a
+			`),
+		},
+		"listmethod5": {
+			content: d(`
+				l = []
+				l.pop()
+			`),
+			out: d(`
+				$$filename:2:6 pop from empty list
+			`),
+		},
+		"listmethod6": {
+			content: d(`
+				l = ["bb", "a", "ccc", "dd"]
+				l.sort(reverse=true)
+				print(l)
+				l.sort(key=len)
+				print(l)
+				l.sort(key=len, reverse=true)
+				print(l)
+				l.sort(len, reverse=true)
+				print(l)
+				l.sort(len, key=len)
+			`),
+			out: d(`
+				[dd, ccc, bb, a]
+				[a, dd, bb, ccc]
+				[ccc, dd, bb, a]
+				[ccc, dd, bb, a]
+				$$filename:10:7 multiple values for argument key to sort()
+			`),
+		},
+		"listmethod7": {
+			content: d(`
+				[1].append(2, x=1)
+			`),
+			out: d(`
+				$$filename:1:11 unknown keyword argument x to list.append()
+			`),
+		},
+		"listmethod8": {
+			content: d(`
+				a = [1, 2, "x", 3]
+				try { a.sort(reverse=true) } catch e { print(e) }
+				print(a)
+				a = [1, 3, 2]
+				a.sort(reverse=true)
+				print(a)
+			`),
+			out: d(`
+				cannot compute: x < 3
+				[1, 2, x, 3]
+				[3, 2, 1]
+			`),
+		},
+		"dictmethod1": {
+			content: d(`
+				d = {"b": 1, "a": 2}
+				print(d.keys(), d.values(), d.items())
+				print(d.get("a"), d.get("z"), d.get("z", 0), d.has("b"), d.has("z"))
+				d.update({"c": 3, "b": 10})
+				print(d, d.pop("a"), d.pop("a", -1), d.delete("c"), d.delete("c"), d)
+			`),
+			out: d(`
+				[b, a] [1, 2] [[b, 1], [a, 2]]
+				2 nil 0 true false
+				{b: 10} 2 -1 true false {b: 10}
+			`),
+		},
+		"dictmethod2": {
+			content: d(`
+				d = {"a": 1, "b": 2, "c": 3}
+				del d["b"]
+				print(d)
+				m = d | {"a": 10, "z": 26}
+				print(d, m)
+				m |= {"y": 25}
+				print(m)
+				l = [1, 2, 3]
+				del l[0], m["a"]
+				print(l, m)
+			`),
+			out: d(`
+				{a: 1, c: 3}
+				{a: 1, c: 3} {a: 10, c: 3, z: 26}
+				{a: 10, c: 3, z: 26, y: 25}
+				[2, 3] {c: 3, z: 26, y: 25}
+			`),
+		},
+		"dictmethod3": {
+			content: d(`
+				d = {"a": 1}
+				del d["b"]
+			`),
+			out: d(`
+				$$filename:2:10 key b is not found
+			`),
+		},
+		"in1": {
+			content: d(`
+				d = {"a": 1, (1,): 2}
+				s = "hello"
+				l = [1, "x", [2]]
+				print("a" in d, "b" in d, "b" not in d, (1,) in d)
+				print("ell" in s, "z" not in s, "" in s)
+				print(1 in l, [2] in l, 3 not in l, 1 + 1 in [2])
+				if "a" in d && "x" in l {
+					print("ok")
+				}
+			`),
+			out: d(`
+				true false true true
+				true true true
+				true true true true
+				ok
+			`),
+		},
+		"in2": {
+			content: d(`
+				print(1 in "123")
+			`),
+			out: d(`
+				$$filename:1:9 left operand of "in" str must be str but got i64
+			`),
+		},
+		"in3": {
+			content: d(`
+				print(1 in 2)
+			`),
+			out: d(`
+				$$filename:1:9 i64 is not iterable
+			`),
+		},
 		"assign": {
 			content: d(`
 				a = 99
@@ -187,6 +828,28 @@ func TestOutput(t *testing.T) {
 				1 a true
 			`),
 		},
+		"assign5": {
+			content: d(`
+				a = 1
+				b = a
+				b += 1
+				print(a, b)
+
+				l = [1, 2, 3]
+				l2 = l
+				l[0] = 9
+				print(l, l2)
+
+				x = l[1]
+				x = 100
+				print(l)
+			`),
+			out: d(`
+				1 2
+				[9, 2, 3] [9, 2, 3]
+				[9, 2, 3]
+			`),
+		},
 		"if1": {
 			content: d(`
 				if 0 {
@@ -278,24 +941,393 @@ func TestOutput(t *testing.T) {
 					if i == 1 {
 						continue
 					}
-
-					if i == 2 {
-						break
+
+					if i == 2 {
+						break
+					}
+
+					print(e)
+				}
+			`),
+			out: d(`
+				1
+			`),
+		},
+		"for3": {
+			content: d(`
+				continue
+			`),
+			out: d(`
+				$$filename:1:1 invalid continue in outside function
+			`),
+		},
+		"for4": {
+			content: d(`
+				i = 0
+				for i < 3 {
+					print(i)
+					i += 1
+				}
+			`),
+			out: d(`
+				0
+				1
+				2
+			`),
+		},
+		"for5": {
+			content: d(`
+				for x in range(3) {
+					print(x)
+				}
+				print(range(5))
+			`),
+			out: d(`
+				0
+				1
+				2
+				range(0, 5, 1)
+			`),
+		},
+		"for6": {
+			content: d(`
+				i = 0
+				outer: for {
+					for e in ["a", "b"] {
+						i += 1
+						if i == 3 {
+							break outer
+						}
+						print(e)
+					}
+				}
+				print(i)
+			`),
+			out: d(`
+				a
+				b
+				3
+			`),
+		},
+		"for7": {
+			content: d(`
+				for {
+					break outer
+				}
+			`),
+			out: d(`
+				$$filename:2:8 label outer is not defined
+			`),
+		},
+		"for8": {
+			content: d(`
+				print(range(1, 2, 0))
+			`),
+			out: d(`
+				$$filename:1:12 range() step cannot be zero
+			`),
+		},
+		"for10": {
+			content: d(`
+				m = -9223372036854775807 - 1
+				print(len(range(m, 9223372036854775807, 3)))
+				for i in range(9223372036854775806, m, m) {
+					print(i)
+				}
+				print(len(range(m, 9223372036854775807)))
+			`),
+			out: d(`
+				6148914691236517205
+				9223372036854775806
+				-2
+				$$filename:6:16 range() has too many values
+			`),
+		},
+		"for9": {
+			content: d(`
+				a = nil
+				for v in a ?? [1, 2] {
+					print(v)
+				}
+				for i, v in a ?? "xy" {
+					print(i, v)
+				}
+				n = 0
+				for n < 2 {
+					n += 1
+				}
+				print(n)
+			`),
+			out: d(`
+				1
+				2
+				0 x
+				1 y
+				2
+			`),
+		},
+		"match1": {
+			content: d(`
+				for x in [1, [2, 3], {"a": 4}, 5] {
+					match x {
+					case 1 { print("one") }
+					case [a, b] if a < b { print(a, b) }
+					case {"a": v} { print(v) }
+					case y { print("default", y) }
+					}
+				}
+			`),
+			out: d(`
+				one
+				2 3
+				4
+				default 5
+			`),
+		},
+		"match2": {
+			content: d(`
+				match 1 {
+				case P{A: 1} { print(1) }
+				}
+			`),
+			out: d(`
+				$$filename:2:14 struct P is not defined
+			`),
+		},
+		"match3": {
+			content: d(`
+				struct Base { ID }
+				struct User {
+					Base
+					Name
+				}
+				for u in [User{Base: Base{ID: 1}, Name: "a"}, User{Base: Base{ID: 2}, Name: "b"}] {
+					match u {
+					case User{ID: 1, Name: n} { print("first", n) }
+					case User{ID: id} { print("other", id) }
+					}
+				}
+				match User{} {
+				case User{Age: 1} { print(1) }
+				}
+			`),
+			out: d(`
+				first a
+				other 2
+				$$filename:13:19 struct User does not have field Age
+			`),
+		},
+		"args1": {
+			content: d(`
+				def f(a, b=2, *rest, **opts) {
+					print(a, b, rest, opts)
+				}
+				f(1)
+				f(1, 3, 4, 5)
+				f(1, x=1, b=9)
+				print(1, 2, sep=", ", end=".\n")
+			`),
+			out: d(`
+				1 2 [] {}
+				1 3 [4, 5] {}
+				1 9 [] {x: 1}
+				1, 2.
+			`),
+		},
+		"args2": {
+			content: d(`
+				def f(a, b) {}
+				f(1, c=2)
+			`),
+			out: d(`
+				$$filename:2:2 unknown keyword argument c to f()
+			`),
+		},
+		"args3": {
+			content: d(`
+				def f(a, b) {}
+				f(1, a=2)
+			`),
+			out: d(`
+				$$filename:2:2 multiple values for argument a to f()
+			`),
+		},
+		"args4": {
+			content: d(`
+				def f(a, b) {}
+				f(b=1, b=2)
+			`),
+			out: d(`
+				$$filename:2:8 duplicate keyword argument b
+			`),
+		},
+		"args5": {
+			content: d(`
+				def f(a=1, b) {}
+			`),
+			out: d(`
+				$$filename:1:13 parameter b without default follows parameter with default
+			`),
+		},
+		"args6": {
+			content: d(`
+				f(a=1, 2)
+			`),
+			out: d(`
+				$$filename:1:9 positional argument follows keyword argument
+			`),
+		},
+		"args7": {
+			content: d(`
+				import math
+				print(math.Add(*[1, 2], x=1))
+			`),
+			out: d(`
+				$$filename:2:15 unknown keyword argument x to Add()
+			`),
+		},
+		"tuple1": {
+			content: d(`
+				def f() {
+					return 1, "a"
+				}
+				t = f()
+				print(t, (1,), (), type(t))
+				printf("%+v\n", t)
+				x, y := t
+				print(x, y)
+			`),
+			out: d(`
+				(1, a) (1,) () tuple
+				(1, "a")
+				1 a
+			`),
+		},
+		"tuple2": {
+			content: d(`
+				t = (1, 2)
+				t[0] = 3
+			`),
+			out: d(`
+				$$filename:2:4 cannot assign to index of tuple
+			`),
+		},
+		"set1": {
+			content: d(`
+				s = {"b", "a", "b"}
+				print(s, set(), type(s))
+				printf("%+v\n", s)
+				print(s | {"c"}, s & {"a"}, s - {"a"})
+			`),
+			out: d(`
+				{b, a} set() set
+				{"b", "a"}
+				{b, a, c} {a} {b}
+			`),
+		},
+		"set2": {
+			content: d(`
+				s = {1}
+				s.remove(2)
+			`),
+			out: d(`
+				$$filename:2:9 2 is not in set
+			`),
+		},
+		"set3": {
+			content: d(`
+				x = {1, 2 +}
+			`),
+			out: d(`
+				$$filename:1:12 identifier is expected
+			`),
+		},
+		"set4": {
+			content: d(`
+				print({len({len({len({len({len({"a"}): 1}): 2}): 3}): 4}): 5}, {
+					1,
+					2
+				})
+			`),
+			out: d(`
+				{1: 5} {1, 2}
+			`),
+		},
+		"hash1": {
+			content: d(`
+				d = {[1]: 2}
+			`),
+			out: d(`
+				$$filename:1:6 unhashable type: list
+			`),
+		},
+		"hash2": {
+			content: d(`
+				s = {1, {2}}
+			`),
+			out: d(`
+				$$filename:1:9 unhashable type: set
+			`),
+		},
+		"hash3": {
+			content: d(`
+				struct S{ A }
+				d = {}
+				d[S{A: 1}] = 1
+			`),
+			out: d(`
+				$$filename:3:10 unhashable type: S (Hash method is not defined)
+			`),
+		},
+		"hash4": {
+			content: d(`
+				struct S{
+					A
+					def Hash() {
+						return "a"
+					}
+				}
+				print({S{A: 1}: 1})
+			`),
+			out: d(`
+				$$filename:7:15 S.Hash() must return i64 but got str
+			`),
+		},
+		"hash5": {
+			content: d(`
+				struct K{
+					A
+					def Hash() {
+						return 0
+					}
+					def Eq(k) {
+						return A == k.A
 					}
-
-					print(e)
 				}
+				d = {K{A: 1}: "a", K{A: 2}: "b"}
+				print(d[K{A: 2}], len(d), (1, "x") in {(1, "x"): 1})
 			`),
 			out: d(`
-				1
+				b 2 true
 			`),
 		},
-		"for3": {
+		"hash6": {
 			content: d(`
-				continue
+				struct K {
+					def Hash() {
+						return 0
+					}
+					def Eq(k) {
+						return k.Z
+					}
+				}
+				a = {K{}: 1}
+				print(a | {1: 2})
+				print(a | {K{}: 2})
 			`),
 			out: d(`
-				$$filename:1:1 invalid continue in outside function
+				{K{}: 1, 1: 2}
+				$$filename:6:11 unknown field name Z in K{}
 			`),
 		},
 		"return1": {
@@ -550,6 +1582,50 @@ func TestOutput(t *testing.T) {
 				[a]
 			`),
 		},
+		"slice2": {
+			content: d(`
+				a = [0, 1, 2, 3, 4, 5]
+				print(a[-2], a[:2], a[4:], a[::-2], a[5:1:-2])
+				a[1:5] = ["x"]
+				print(a)
+				del a[0], a[-1]
+				print(a)
+				print("hello"[-4:-1])
+			`),
+			out: d(`
+				4 [0, 1] [4, 5] [5, 3, 1] [5, 3]
+				[0, x, 5]
+				[x]
+				ell
+			`),
+		},
+		"slice3": {
+			content: d(`
+				a = [1, 2, 3]
+				print(a[-4])
+			`),
+			out: d(`
+				$$filename:2:11 index out of range [-4] with length 3
+			`),
+		},
+		"slice4": {
+			content: d(`
+				a = [1, 2, 3]
+				print(a[::0])
+			`),
+			out: d(`
+				$$filename:2:10 slice step cannot be zero
+			`),
+		},
+		"slice5": {
+			content: d(`
+				a = [1, 2, 3]
+				a[::2] = [1]
+			`),
+			out: d(`
+				$$filename:2:4 cannot assign 1 values to extended slice of size 2
+			`),
+		},
 		"dict1": {
 			content: d(`
 				b = 3
@@ -574,6 +1650,467 @@ func TestOutput(t *testing.T) {
 				100
 			`),
 		},
+		"struct1": {
+			content: d(`
+				struct Person {
+					Name
+					Age
+
+					def Birthday() {
+						Age += 1
+					}
+				}
+
+				p = Person{Name: "alice", Age: 3}
+				p.Birthday()
+				p.Name = "bob"
+				print(p)
+			`),
+			out: d(`
+				Person{Name:bob, Age:4}
+			`),
+		},
+		"struct2": {
+			content: d(`
+				struct A { X }
+				struct B { X }
+				struct C {
+					A
+					B
+				}
+
+				c = C{}
+				c.A.X = 1
+				print(c)
+				print(c.X)
+			`),
+			out: d(`
+				C{A:A{X:1}, B:B{}}
+				$$filename:11:8 ambiguous selector X in C
+			`),
+		},
+		"struct3": {
+			content: d(`
+				struct A { X }
+				struct B { A }
+
+				b = B{A: 1}
+			`),
+			out: d(`
+				$$filename:4:10 embedded A must be A but got 1
+			`),
+		},
+		"operator1": {
+			content: d(`
+				struct S {
+					X
+					def Add(s) {
+						return X + s.Y
+					}
+				}
+
+				print(S{X: 1} + S{X: 2})
+			`),
+			out: d(`
+				$$filename:4:15 unknown field name Y in S{X:2}
+			`),
+		},
+		"operator2": {
+			content: d(`
+				struct S { X }
+
+				print(S{X: 1} - S{X: 2})
+			`),
+			out: d(`
+				$$filename:3:15 cannot compute: S{X:1} - S{X:2}
+			`),
+		},
+		"operator3": {
+			content: d(`
+				struct S {
+					def Len() {
+						return "a"
+					}
+					def String() {
+						return 1
+					}
+				}
+
+				print(len(S{}))
+			`),
+			out: d(`
+				$$filename:10:10 S.Len() must return i64 but got str
+			`),
+		},
+		"operator4": {
+			content: d(`
+				struct S {
+					def String() {
+						return 1
+					}
+				}
+
+				print([S{}])
+				print(S{})
+			`),
+			out: d(`
+				[S{}]
+				$$filename:8:6 S.String() must return str but got i64
+			`),
+		},
+		"operator5": {
+			content: d(`
+				struct S { X }
+
+				s = S{X: 1}
+				print(s[0])
+			`),
+			out: d(`
+				$$filename:4:10 S is not indexable (Index method is not defined)
+			`),
+		},
+		"operator6": {
+			content: d(`
+				struct B {
+					def Iter() {
+						return 1 / 0
+					}
+				}
+
+				b = B{}
+				try { list(b) } catch e { print(e) }
+				try { sorted(b) } catch e { print(e) }
+				try { print(1 in b) } catch e { print(e) }
+				print(",".join(b))
+			`),
+			out: d(`
+				division by zero
+				division by zero
+				division by zero
+				$$filename:3:12 division by zero
+			`),
+		},
+		"operator7": {
+			content: d(`
+				struct E {
+					def Eq(x) {
+						return x.Z
+					}
+				}
+
+				try { print([E{}].contains(E{})) } catch e { print(e) }
+				try { print([E{}] == [E{}]) } catch e { print(e) }
+				try { print({"a": E{}} == {"a": E{}}) } catch e { print(e) }
+				print([E{}].index(1))
+			`),
+			out: d(`
+				unknown field name Z in E{}
+				unknown field name Z in E{}
+				unknown field name Z in E{}
+				$$filename:3:11 selector 1 is not a module or struct
+			`),
+		},
+		"interface1": {
+			content: d(`
+				interface Reader {
+					Read(n)
+				}
+
+				struct S {
+					def Read() {
+						return ""
+					}
+				}
+
+				struct T {}
+
+				def f(r: Reader) {
+					return r.Read(1)
+				}
+
+				print(implements(S{}, Reader), Reader)
+				f(T{})
+			`),
+			out: d(`
+				false Reader
+				$$filename:18:2 argument r to f(): T does not implement Reader (missing method Read(n))
+			`),
+		},
+		"interface2": {
+			content: d(`
+				interface Reader {
+					Read(n)
+				}
+
+				struct S {
+					def Read() {
+						return ""
+					}
+				}
+
+				def f(r: Reader) {
+					return r.Read(1)
+				}
+
+				f(S{})
+			`),
+			out: d(`
+				$$filename:15:2 argument r to f(): S does not implement Reader (wrong signature of method Read, want Read(n))
+			`),
+		},
+		"interface4": {
+			content: d(`
+				interface Reader {
+					Read(n)
+				}
+
+				struct S {
+					def Read(n) {
+						return ""
+					}
+				}
+				struct T {}
+
+				def f(a, r: Reader) {}
+				def g() {
+					f(1, S{})
+					f(1, r=T{})
+				}
+				def h(f) {
+					f(1, T{})
+				}
+				def i() {
+					for f in [print] {
+						f(1, T{})
+					}
+				}
+				struct U {
+					f
+					def Run() {
+						f(1, T{})
+					}
+				}
+				print("ok")
+			`),
+			out: d(`
+				$$filename:15:9 warning: arg r to f(): T does not implement Reader (missing method Read(n))
+				ok
+			`),
+		},
+		"interface3": {
+			content: d(`
+				def f(r: 1) {
+					return r
+				}
+			`),
+			out: d(`
+				$$filename:1:10 annotation of parameter r must be interface but got i64
+			`),
+		},
+		"receiver1": {
+			content: d(`
+				struct Person {
+					Name
+					Age
+
+					def Birthday() {
+						Age += 1
+					}
+
+					def Older(n) {
+						for i in range(n) {
+							Birthday()
+						}
+						return Age
+					}
+
+					def Rename(Name) {
+						self.Name = Name
+					}
+				}
+
+				p = Person{Name: "alice", Age: 3}
+				print(p.Older(2), p.Age)
+				p.Rename("bob")
+				print(p.Name)
+			`),
+			out: d(`
+				5 5
+				bob
+			`),
+		},
+		"receiver2": {
+			content: d(`
+				struct Counter {
+					N
+
+					def (c) Incr() {
+						c.N += 1
+						return c
+					}
+				}
+
+				c = Counter{N: 0}
+				c.Incr().Incr()
+				print(c.N)
+			`),
+			out: d(`
+				2
+			`),
+		},
+		"receiver3": {
+			content: d(`
+				struct Box {
+					Vals
+
+					def Reset(v) {
+						Vals = [v]
+					}
+				}
+
+				def add(b, v) {
+					b.Vals.append(v)
+					return b
+				}
+
+				b = Box{Vals: [1]}
+
+				# args are copied
+				b2 = add(b, 2)
+				print(b, b2)
+
+				# assignment shares the value
+				b3 = b
+				b3.Vals.append(3)
+				print(b)
+
+				# the receiver is shared
+				l = [Box{Vals: []}]
+				d = {"k": Box{Vals: []}}
+				l[0].Reset(4)
+				d["k"].Reset(5)
+				print(l, d)
+			`),
+			out: d(`
+				Box{Vals:[1]} Box{Vals:[1, 2]}
+				Box{Vals:[1, 3]}
+				[Box{Vals:[4]}] {k: Box{Vals:[5]}}
+			`),
+		},
+		"receiver4": {
+			content: d(`
+				def (x) f() {
+					return 1
+				}
+			`),
+			out: d(`
+				$$filename:1:1 receiver x is allowed only in struct method
+			`),
+		},
+		"enum1": {
+			content: d(`
+				enum Shape {
+					Circle(r), Rect(w, h), Empty
+				}
+
+				print(Shape.Rect(1, 2), Shape.Empty, Shape)
+				Shape.Circle()
+			`),
+			out: d(`
+				Shape.Rect(1, 2) Shape.Empty Shape
+				$$filename:6:13 missing argument r to Shape.Circle()
+			`),
+		},
+		"enum2": {
+			content: d(`
+				enum Shape { Circle(r) }
+
+				print(Shape.Square)
+			`),
+			out: d(`
+				$$filename:3:12 unknown variant Square in enum Shape
+			`),
+		},
+		"enum3": {
+			content: d(`
+				enum Shape { Circle(r) }
+
+				match Shape.Circle(1) {
+					case Shape.Circle(a, b) {
+						print(a)
+					}
+				}
+			`),
+			out: d(`
+				$$filename:4:19 wrong number of fields in pattern Shape.Circle: want 1 but got 2
+			`),
+		},
+		"enum4": {
+			content: d(`
+				enum Shape { Circle(r), Circle }
+			`),
+			out: d(`
+				$$filename:1:25 duplicate variant Circle in enum Shape
+			`),
+		},
+		"enum5": {
+			content: d(`
+				enum Shape { Circle(r) }
+				def f(s) {
+					s.r.append(2)
+					print(s)
+				}
+				c = Shape.Circle([1])
+				f(c)
+				c2 = c
+				c2.r.append(3)
+				print(c)
+			`),
+			out: d(`
+				Shape.Circle([1, 2])
+				Shape.Circle([1, 3])
+			`),
+		},
+		"enum6": {
+			content: d(`
+				enum Shape { Circle(r), Rect(w, h), Empty }
+
+				match Shape.Circle(2) {
+					case Shape.Rect(w, h) { print(w * h) }
+				}
+				def area(s) {
+					match s {
+						case Shape.Circle(r) { return r * r * 3 }
+						case Shape.Rect(w, 0) { return 0 }
+						case Shape.Rect(w, h) if w > 0 { return w * h }
+						case Shape.Empty { return 0 }
+					}
+				}
+				match Shape.Empty {
+					case Shape.Circle(r) { print(r) }
+					case _ { print("other") }
+				}
+				match Shape.Empty {
+					case Shape.Circle(r) {}
+					case Shape.Rect(w, h) {}
+					case Shape.Empty { print("empty") }
+				}
+			`),
+			out: d(`
+				$$filename:3:1 warning: match over Shape is not exhaustive: missing Circle, Empty
+				$$filename:7:2 warning: match over Shape is not exhaustive: missing Rect
+				other
+				empty
+			`),
+		},
+		"zerodiv": {
+			content: d(`
+				a = 1 / 0
+			`),
+			out: d(`
+				$$filename:1:7 division by zero
+			`),
+		},
 		"import1": {
 			content: d(`
 				import import1_2
@@ -603,7 +2140,10 @@ func TestOutput(t *testing.T) {
 	}
 
 	for name, tc := range tests {
+		name, tc := name, tc
 		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
 			td := t.TempDir()
 
 			files := map[string]string{name: tc.content}
@@ -629,11 +2169,13 @@ func TestOutput(t *testing.T) {
 				}
 			}
 
-			// err is fine as some tests make sure error case
-			result, _ := exec.Command("./shiba", dfname).CombinedOutput()
+			// every test runs on its own environment, so they can run in parallel.
+			// exit code is ignored as some tests make sure error case
+			var result bytes.Buffer
+			interpret(newenvironment(&result, &result), dfname)
 			tc.out = strings.Replace(tc.out, "$$filename", dfname, -1)
 
-			if diff := cmp.Diff(tc.out, string(result)); diff != "" {
+			if diff := cmp.Diff(tc.out, result.String()); diff != "" {
 				t.Fatalf("(-want +got):\n%s", diff)
 			}
 		})
@@ -2,14 +2,40 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"path/filepath"
 	"strings"
 )
 
-var env *environment
-
+// environment holds the whole state of a single shiba execution.
+// Nothing is shared between environments, so multiple executions can run in parallel in one process.
 type environment struct {
 	modules map[string]*module
+
+	// the execution writes its output to stdout and its error messages to stderr.
+	stdout io.Writer
+	stderr io.Writer
+
+	// the call of the running builtin function. Errors in the function called back by the builtin are reported here.
+	caller node
+}
+
+func newenvironment(stdout, stderr io.Writer) *environment {
+	return &environment{
+		modules: map[string]*module{},
+		stdout:  stdout,
+		stderr:  stderr,
+	}
+}
+
+// call calls the callable obj. This is used by builtin functions which take a function as the argument.
+func (e *environment) call(fn obj, args ...obj) (obj, error) {
+	o, err := callobj(e, e.caller, fn, args, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
 }
 
 func (e *environment) String() string {
@@ -51,13 +77,14 @@ func (e *environment) findmodule(mod *module) (*module, error) {
 	return m, nil
 }
 
-func (e *environment) createfuncscope(mod *module) error {
+// createfuncscope creates the scope of the function call. recv is the receiver if the function is a method.
+func (e *environment) createfuncscope(mod *module, recv *oStruct) error {
 	m, err := e.findmodule(mod)
 	if err != nil {
 		return err
 	}
 
-	m.createfuncscope()
+	m.createfuncscope(recv)
 	return nil
 }
 
@@ -101,7 +128,7 @@ func (e *environment) setstruct(mod *module, name string, s *structdef) error {
 	return nil
 }
 
-func (e *environment) setobj(mod *module, name string, o *obj) error {
+func (e *environment) setobj(mod *module, name string, o obj) error {
 	m, err := e.findmodule(mod)
 	if err != nil {
 		return err
@@ -111,6 +138,16 @@ func (e *environment) setobj(mod *module, name string, o *obj) error {
 	return nil
 }
 
+func (e *environment) defobj(mod *module, name string, o obj) error {
+	m, err := e.findmodule(mod)
+	if err != nil {
+		return err
+	}
+
+	m.defobj(name, o)
+	return nil
+}
+
 func (e *environment) getstruct(mod *module, name string) (*structdef, bool) {
 	m, err := e.findmodule(mod)
 	if err != nil {
@@ -120,7 +157,7 @@ func (e *environment) getstruct(mod *module, name string) (*structdef, bool) {
 	return m.getstruct(name)
 }
 
-func (e *environment) getobj(mod *module, name string) (*obj, bool) {
+func (e *environment) getobj(mod *module, name string) (obj, bool) {
 	m, err := e.findmodule(mod)
 	if err != nil {
 		return nil, false
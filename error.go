@@ -28,10 +28,20 @@ func newsberr2(l *loc, format string, args ...any) shibaErr {
 	return &sberr{l: l, msg: fmt.Sprintf(format, args...)}
 }
 
-func newTypeMismatchErr(n node, expected, actual objtyp) shibaErr {
+// sberrof converts err into shibaErr at n. err which is already shibaErr,
+// such as the one in the method called back by the operation, is returned as it is.
+func sberrof(n node, err error) shibaErr {
+	if se, ok := err.(shibaErr); ok {
+		return se
+	}
+
+	return newsberr(n, "%s", err)
+}
+
+func newTypeMismatchErr(n node, expected string, actual obj) shibaErr {
 	return &sberr{
 		l:   n.token().loc,
-		msg: fmt.Sprintf("type %s is expected but got %s", expected, actual),
+		msg: fmt.Sprintf("type %s is expected but got %s", expected, actual.typename()),
 	}
 }
 
@@ -54,10 +64,21 @@ func (e *errUndefinedIdent) Error() string {
 
 type errDictKeyNotFound struct {
 	l   *loc
-	key *obj
+	key obj
 }
 
 func (e *errDictKeyNotFound) loc() *loc { return e.l }
 func (e *errDictKeyNotFound) Error() string {
 	return fmt.Sprintf("key %s is not found", e.key)
 }
+
+// errExit is returned when exit() is called.
+// It is propagated to the top level and terminates the execution with the code.
+type errExit struct {
+	code int
+}
+
+func (e *errExit) loc() *loc { return nil }
+func (e *errExit) Error() string {
+	return fmt.Sprintf("exit %d", e.code)
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// fmtspec specifies how an obj is formatted.
+// It is built from the format spec in f-string like "{x:>8.3f}".
+type fmtspec struct {
+	// padding character. ' ' by default.
+	fill rune
+	// '<' (left), '>' (right), '^' (center), '=' (pad after sign, only for numbers)
+	// or 0 (numbers are aligned right, others are left).
+	align rune
+	// '+' (sign for both positive and negative), ' ' (space for positive) or 0 (sign only for negative).
+	sign rune
+	// alternate form. prefix 0x, 0o or 0b is put on integer.
+	alt bool
+	// minimum width in runes.
+	width int
+	// thousands separator ',' or '_'. 0 if not specified.
+	group rune
+	// digits after decimal point for float, max runes for str. -1 if not specified.
+	prec int
+	// format type such as 'd', 'x', 'f' or 's'. 0 if not specified.
+	verb rune
+}
+
+func newfmtspec() *fmtspec {
+	return &fmtspec{fill: ' ', prec: -1}
+}
+
+// parsefmtspec parses the format spec in f-string. The syntax is:
+//
+//	[[fill]align][sign][#][0][width][grouping][.precision][type]
+//
+//	fill:      any character
+//	align:     "<" | ">" | "^" | "="
+//	sign:      "+" | "-" | " "
+//	width:     digits
+//	grouping:  "," | "_"
+//	precision: digits
+//	type:      "d" | "x" | "X" | "o" | "b" | "f" | "F" | "e" | "E" | "g" | "G" | "%" | "s"
+func parsefmtspec(spec string) (*fmtspec, error) {
+	s := newfmtspec()
+	rs := []rune(spec)
+	i := 0
+
+	isalign := func(r rune) bool { return r == '<' || r == '>' || r == '^' || r == '=' }
+
+	if len(rs) >= 2 && isalign(rs[1]) {
+		s.fill = rs[0]
+		s.align = rs[1]
+		i = 2
+	} else if len(rs) >= 1 && isalign(rs[0]) {
+		s.align = rs[0]
+		i = 1
+	}
+
+	if i < len(rs) && (rs[i] == '+' || rs[i] == '-' || rs[i] == ' ') {
+		if rs[i] != '-' {
+			s.sign = rs[i]
+		}
+		i++
+	}
+
+	if i < len(rs) && rs[i] == '#' {
+		s.alt = true
+		i++
+	}
+
+	if i < len(rs) && rs[i] == '0' {
+		// zero padding is the same as fill "0" with align "="
+		if s.align == 0 {
+			s.fill = '0'
+			s.align = '='
+		}
+		i++
+	}
+
+	start := i
+	for i < len(rs) && isdigit(rs[i]) {
+		i++
+	}
+	if start < i {
+		w, err := fmtnum(string(rs[start:i]))
+		if err != nil {
+			return nil, fmt.Errorf("format spec %q: width %w", spec, err)
+		}
+		s.width = w
+	}
+
+	if i < len(rs) && (rs[i] == ',' || rs[i] == '_') {
+		s.group = rs[i]
+		i++
+	}
+
+	if i < len(rs) && rs[i] == '.' {
+		i++
+		start := i
+		for i < len(rs) && isdigit(rs[i]) {
+			i++
+		}
+		if start == i {
+			return nil, fmt.Errorf("format spec %q: precision is missing after '.'", spec)
+		}
+		p, err := fmtnum(string(rs[start:i]))
+		if err != nil {
+			return nil, fmt.Errorf("format spec %q: precision %w", spec, err)
+		}
+		s.prec = p
+	}
+
+	if i < len(rs) {
+		if !strings.ContainsRune("dxXobfFeEgG%s", rs[i]) {
+			return nil, fmt.Errorf("format spec %q: unknown format type %q", spec, rs[i])
+		}
+		s.verb = rs[i]
+		i++
+	}
+
+	if i < len(rs) {
+		return nil, fmt.Errorf("format spec %q: invalid format spec", spec)
+	}
+
+	return s, nil
+}
+
+// fmtnum parses the digits for width and precision.
+// They are limited by maxrepeat as padding with them builds a str of that length.
+func fmtnum(digits string) (int, error) {
+	n, err := strconv.Atoi(digits)
+	if err != nil || n > maxrepeat {
+		return 0, fmt.Errorf("is too large: %s", digits)
+	}
+	return n, nil
+}
+
+// formatobj formats the obj as the spec.
+func formatobj(o obj, s *fmtspec) (string, error) {
+	switch v := o.(type) {
+	case *oI64:
+		if isfloatverb(s.verb) {
+			return formatfloat(float64(v.val), s)
+		}
+
+		return formatint(big.NewInt(v.val), s, o)
+
+	case *oBigInt:
+		if isfloatverb(s.verb) {
+			return formatfloat(bigtof64(v.val), s)
+		}
+
+		return formatint(v.val, s, o)
+
+	case *oF64:
+		if s.verb != 0 && !isfloatverb(s.verb) {
+			return "", fmt.Errorf("format type %q is invalid for %s", s.verb, o.typename())
+		}
+
+		return formatfloat(v.val, s)
+
+	case *oStr:
+		if s.verb != 0 && s.verb != 's' {
+			return "", fmt.Errorf("format type %q is invalid for %s", s.verb, o.typename())
+		}
+
+		str := v.String()
+		if s.prec >= 0 && s.prec < utf8.RuneCountInString(str) {
+			str = string([]rune(str)[:s.prec])
+		}
+
+		return pad("", str, s, '<'), nil
+	}
+
+	if s.verb != 0 && s.verb != 's' {
+		return "", fmt.Errorf("format type %q is invalid for %s", s.verb, o.typename())
+	}
+
+	return pad("", o.String(), s, '<'), nil
+}
+
+func isfloatverb(verb rune) bool {
+	return strings.ContainsRune("fFeEgG%", verb)
+}
+
+func formatint(v *big.Int, s *fmtspec, o obj) (string, error) {
+	if s.prec >= 0 {
+		return "", fmt.Errorf("precision is not allowed for %s", o.typename())
+	}
+
+	base := 10
+	prefix := ""
+	switch s.verb {
+	case 0, 'd':
+	case 'x', 'X':
+		base = 16
+		prefix = "0x"
+	case 'o':
+		base = 8
+		prefix = "0o"
+	case 'b':
+		base = 2
+		prefix = "0b"
+	default:
+		return "", fmt.Errorf("format type %q is invalid for %s", s.verb, o.typename())
+	}
+
+	digits := new(big.Int).Abs(v).Text(base)
+	if s.group != 0 {
+		if base != 10 && s.group == ',' {
+			return "", fmt.Errorf("',' is not allowed with format type %q", s.verb)
+		}
+
+		// Python groups non-decimal digits by 4
+		if base == 10 {
+			digits = group(digits, 3, s.group)
+		} else {
+			digits = group(digits, 4, s.group)
+		}
+	}
+
+	if s.verb == 'X' {
+		digits = strings.ToUpper(digits)
+		prefix = "0X"
+	}
+
+	head := signof(v.Sign() < 0, s)
+	if s.alt {
+		head += prefix
+	}
+
+	return pad(head, digits, s, '>'), nil
+}
+
+func formatfloat(v float64, s *fmtspec) (string, error) {
+	var body string
+	neg := v < 0
+	if neg {
+		v = -v
+	}
+
+	switch s.verb {
+	case 0:
+		if s.prec >= 0 {
+			// like Python, precision without a verb means significant digits
+			body = strconv.FormatFloat(v, 'g', s.prec, 64)
+		} else {
+			body = (&oF64{val: v}).String()
+		}
+	case '%':
+		body = strconv.FormatFloat(v*100, 'f', precor(s.prec, 6), 64) + "%"
+	case 'g', 'G':
+		body = strconv.FormatFloat(v, byte(s.verb), s.prec, 64)
+	default:
+		body = strconv.FormatFloat(v, byte(s.verb), precor(s.prec, 6), 64)
+	}
+
+	if s.group != 0 {
+		// group the integer part only
+		i := strings.IndexFunc(body, func(r rune) bool { return !isdigit(r) })
+		if i < 0 {
+			i = len(body)
+		}
+		body = group(body[:i], 3, s.group) + body[i:]
+	}
+
+	return pad(signof(neg, s), body, s, '>'), nil
+}
+
+// group inserts sep into digits every n digits from the right.
+func group(digits string, n int, sep rune) string {
+	var sb strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%n == 0 {
+			sb.WriteRune(sep)
+		}
+		sb.WriteRune(d)
+	}
+	return sb.String()
+}
+
+func precor(prec, dflt int) int {
+	if prec < 0 {
+		return dflt
+	}
+	return prec
+}
+
+func signof(neg bool, s *fmtspec) string {
+	if neg {
+		return "-"
+	}
+
+	if s.sign != 0 {
+		return string(s.sign)
+	}
+
+	return ""
+}
+
+// pad pads head + body up to the width in the spec.
+// head is the sign and prefix of number, which is put before the padding when align is "=".
+func pad(head, body string, s *fmtspec, dfltalign rune) string {
+	n := s.width - utf8.RuneCountInString(head) - utf8.RuneCountInString(body)
+	if n <= 0 {
+		return head + body
+	}
+
+	align := s.align
+	if align == 0 {
+		align = dfltalign
+	}
+
+	fill := strings.Repeat(string(s.fill), n)
+	switch align {
+	case '<':
+		return head + body + fill
+	case '^':
+		l := strings.Repeat(string(s.fill), n/2)
+		r := strings.Repeat(string(s.fill), n-n/2)
+		return l + head + body + r
+	case '=':
+		return head + fill + body
+	default:
+		return fill + head + body
+	}
+}
+
+// sprintf formats args according to the format like Go's fmt.Sprintf.
+// Each directive is:
+//
+//	%[flags][width][.precision]verb
+//
+//	flags:     "-" (left align) | "+" (sign) | " " (space for positive) | "#" (prefix 0x, 0o, 0b) | "0" (zero padding)
+//	width:     digits | "*"
+//	precision: digits | "*"
+//	verb:      "v" | "d" | "s" | "q" | "t" | "x" | "X" | "o" | "b" | "f" | "F" | "e" | "E" | "g" | "G" | "%"
+//
+// %v is the same as print(), and %+v quotes strings in lists, dicts and structs.
+func sprintf(format string, args []obj) (string, error) {
+	var sb strings.Builder
+	rs := []rune(format)
+	argi := 0
+
+	nextarg := func(verb rune) (obj, error) {
+		if argi >= len(args) {
+			return nil, fmt.Errorf("missing argument for %%%c", verb)
+		}
+		a := args[argi]
+		argi++
+		return a, nil
+	}
+
+	// reads digits or "*" for width and precision
+	readnum := func(i int, verb rune) (int, int, error) {
+		if i < len(rs) && rs[i] == '*' {
+			a, err := nextarg(verb)
+			if err != nil {
+				return 0, i, err
+			}
+
+			n, ok := a.(*oI64)
+			if !ok || n.val < 0 {
+				return 0, i, fmt.Errorf("'*' in format requires non-negative i64 but got %s", a.typename())
+			}
+			if n.val > maxrepeat {
+				return 0, i, fmt.Errorf("'*' in format is too large: %d", n.val)
+			}
+			return int(n.val), i + 1, nil
+		}
+
+		start := i
+		for i < len(rs) && isdigit(rs[i]) {
+			i++
+		}
+		if start == i {
+			return 0, i, nil
+		}
+		n, err := fmtnum(string(rs[start:i]))
+		if err != nil {
+			return 0, i, fmt.Errorf("width or precision in format %w", err)
+		}
+		return n, i, nil
+	}
+
+	for i := 0; i < len(rs); i++ {
+		if rs[i] != '%' {
+			sb.WriteRune(rs[i])
+			continue
+		}
+
+		i++
+		if i >= len(rs) {
+			return "", fmt.Errorf("verb is missing after '%%' at the end of format")
+		}
+
+		if rs[i] == '%' {
+			sb.WriteRune('%')
+			continue
+		}
+
+		s := newfmtspec()
+		plus := false
+		zero := false
+	flags:
+		for ; i < len(rs); i++ {
+			switch rs[i] {
+			case '-':
+				s.align = '<'
+			case '+':
+				s.sign = '+'
+				plus = true
+			case ' ':
+				if s.sign == 0 {
+					s.sign = ' '
+				}
+			case '#':
+				s.alt = true
+			case '0':
+				zero = true
+			default:
+				break flags
+			}
+		}
+
+		var err error
+		s.width, i, err = readnum(i, '*')
+		if err != nil {
+			return "", err
+		}
+
+		if i < len(rs) && rs[i] == '.' {
+			s.prec, i, err = readnum(i+1, '*')
+			if err != nil {
+				return "", err
+			}
+		}
+
+		if i >= len(rs) {
+			return "", fmt.Errorf("verb is missing at the end of format")
+		}
+
+		verb := rs[i]
+		arg, err := nextarg(verb)
+		if err != nil {
+			return "", err
+		}
+
+		// unlike f-string, everything is aligned right by default.
+		// "-" wins over "0" as Go does.
+		if s.align == 0 {
+			s.align = '>'
+			if zero {
+				s.fill = '0'
+				s.align = '='
+			}
+		}
+
+		out, err := formatverb(arg, verb, s, plus)
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(out)
+	}
+
+	if argi < len(args) {
+		return "", fmt.Errorf("too many arguments for format: %d given but %d used", len(args), argi)
+	}
+
+	return sb.String(), nil
+}
+
+// formatverb formats an arg for a directive in sprintf().
+func formatverb(o obj, verb rune, s *fmtspec, plus bool) (string, error) {
+	invalid := func() (string, error) {
+		return "", fmt.Errorf("%%%c is invalid for %s", verb, o.typename())
+	}
+
+	switch verb {
+	case 'v':
+		switch o.(type) {
+		case *oI64, *oBigInt, *oF64:
+			return formatobj(o, s)
+		}
+
+		str := o.String()
+		if plus {
+			str = inspect(o)
+		}
+		return pad("", str, s, '<'), nil
+
+	case 's':
+		s.verb = 's'
+		return formatobj(newstr(o.String()), s)
+
+	case 'q':
+		str, ok := o.(*oStr)
+		if !ok {
+			return invalid()
+		}
+		return pad("", strconv.Quote(str.String()), s, '<'), nil
+
+	case 't':
+		if _, ok := o.(*oBool); !ok {
+			return invalid()
+		}
+		return pad("", o.String(), s, '<'), nil
+
+	case 'd', 'x', 'X', 'o', 'b':
+		switch v := o.(type) {
+		case *oI64, *oBigInt:
+			s.verb = verb
+			return formatobj(o, s)
+
+		case *oStr:
+			// hex dump of the bytes as Go does
+			if verb != 'x' && verb != 'X' {
+				return invalid()
+			}
+			h := fmt.Sprintf("%x", v.val)
+			if verb == 'X' {
+				h = strings.ToUpper(h)
+			}
+			return pad("", h, s, '<'), nil
+		}
+		return invalid()
+
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		switch o.(type) {
+		case *oI64, *oBigInt, *oF64:
+			s.verb = verb
+			return formatobj(o, s)
+		}
+		return invalid()
+	}
+
+	return "", fmt.Errorf("unknown verb %%%c", verb)
+}
+
+// inspect returns the string representation of the obj where strings are quoted
+// so that the types of the values in a list, dict and struct are distinguishable.
+func inspect(o obj) string {
+	switch v := o.(type) {
+	case *oStr:
+		return strconv.Quote(v.String())
+
+	case *oList:
+		ss := make([]string, len(v.vals))
+		for i, val := range v.vals {
+			ss[i] = inspect(val)
+		}
+		return "[" + strings.Join(ss, ", ") + "]"
+
+	case *oTuple:
+		ss := make([]string, len(v.vals))
+		for i, val := range v.vals {
+			ss[i] = inspect(val)
+		}
+		if len(ss) == 1 {
+			return "(" + ss[0] + ",)"
+		}
+		return "(" + strings.Join(ss, ", ") + ")"
+
+	case *oDict:
+		ss := []string{}
+		for e := v.dict.entries.Front(); e != nil; e = e.Next() {
+			de := e.Value.(*dictentry)
+			ss = append(ss, inspect(de.k)+": "+inspect(de.v))
+		}
+		return "{" + strings.Join(ss, ", ") + "}"
+
+	case *oSet:
+		if v.dict.size() == 0 {
+			return "set()"
+		}
+
+		ss := []string{}
+		for _, val := range v.dict.keys() {
+			ss = append(ss, inspect(val))
+		}
+		return "{" + strings.Join(ss, ", ") + "}"
+
+	case *oStruct:
+		ss := []string{}
+		for _, k := range v.def.vars {
+			val, ok := v.fields[k]
+			if !ok {
+				continue
+			}
+			ss = append(ss, k+":"+inspect(val))
+		}
+		return v.def.name + "{" + strings.Join(ss, ", ") + "}"
+	}
+
+	return o.String()
+}
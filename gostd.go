@@ -11,7 +11,7 @@ type gostdmodules struct {
 
 type gostdmodobj struct {
 	name string
-	o    *obj
+	o    obj
 }
 
 func (g *gostdmodules) objs(modname string) ([]*gostdmodobj, bool) {
@@ -24,25 +24,23 @@ var gostdmods = &gostdmodules{mods: map[string][]*gostdmodobj{
 	"math": {
 		{
 			"Pi",
-			&obj{
-				typ:  tF64,
-				fval: math.Pi,
-			},
+			&oF64{val: math.Pi},
 		},
 		{
 			"Add",
-			&obj{
-				typ: tGoStdModFunc,
-				gostdmodfunc: func(objs ...*obj) (*obj, error) {
+			&oGoStdModFunc{
+				name: "Add",
+				body: func(env *environment, kw *kwargs, objs ...obj) (obj, error) {
 					result := int64(0)
 					for _, o := range objs {
-						if o.typ != tI64 {
+						i, ok := o.(*oI64)
+						if !ok {
 							return NIL, fmt.Errorf("arg for add() must be i64")
 						}
 
-						result += o.ival
+						result += i.val
 					}
-					return &obj{typ: tI64, ival: result}, nil
+					return &oI64{val: result}, nil
 				},
 			},
 		},
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+)
+
+// hashbytes hashes b. tag is the type name so that the values of different types are unlikely to collide.
+func hashbytes(tag string, b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(tag))
+	h.Write(b)
+	return h.Sum64()
+}
+
+func hashuint(tag string, v uint64) uint64 {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return hashbytes(tag, b[:])
+}
+
+// errunhashable is returned when the mutable obj is used as a dict key.
+func errunhashable(o obj) error {
+	return fmt.Errorf("unhashable type: %s", o.typename())
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// methodsig is the method signature declared in the interface.
+type methodsig struct {
+	name   string
+	params []*param
+}
+
+func (ms *methodsig) String() string {
+	names := make([]string, len(ms.params))
+	for i, p := range ms.params {
+		switch {
+		case p.variadic:
+			names[i] = "*" + p.name
+		case p.kwvariadic:
+			names[i] = "**" + p.name
+		default:
+			names[i] = p.name
+		}
+	}
+	return ms.name + "(" + strings.Join(names, ", ") + ")"
+}
+
+// arity returns the number of the required positional params and the max number of the positional args.
+// max is -1 if the params have variadic one.
+func arity(params []*param) (int, int) {
+	required, max := 0, 0
+	for _, p := range params {
+		switch {
+		case p.variadic:
+			max = -1
+		case p.kwvariadic:
+		default:
+			if p.dflt == nil {
+				required++
+			}
+			if max != -1 {
+				max++
+			}
+		}
+	}
+
+	return required, max
+}
+
+func haskwvariadic(params []*param) bool {
+	for _, p := range params {
+		if p.kwvariadic {
+			return true
+		}
+	}
+
+	return false
+}
+
+// accepts reports if the method can be called in every way the signature can be called.
+// Param names are not compared.
+func (ms *methodsig) accepts(m *oMethod) bool {
+	sreq, smax := arity(ms.params)
+	mreq, mmax := arity(m.params)
+	if mreq > sreq {
+		return false
+	}
+
+	if mmax != -1 && (smax == -1 || mmax < smax) {
+		return false
+	}
+
+	return !haskwvariadic(ms.params) || haskwvariadic(m.params)
+}
+
+// check returns the reason why o does not implement the interface, or nil if it does.
+// Only structs can implement interfaces.
+func (o *oInterface) check(x obj) error {
+	s, ok := x.(*oStruct)
+	if !ok {
+		return fmt.Errorf("%s does not implement %s (only struct can implement interface)", x.typename(), o.name)
+	}
+
+	return o.checkdef(s.def)
+}
+
+// checkdef returns the reason why the struct does not implement the interface, or nil if it does.
+// Promoted methods from the embedded structs are in the method set too.
+func (o *oInterface) checkdef(sd *structdef) error {
+	for _, ms := range o.methods {
+		m, ok := sd.findmethod(ms.name)
+		if !ok {
+			return fmt.Errorf("%s does not implement %s (missing method %s)", sd.name, o.name, ms)
+		}
+
+		if !ms.accepts(m) {
+			return fmt.Errorf("%s does not implement %s (wrong signature of method %s, want %s)", sd.name, o.name, ms.name, ms)
+		}
+	}
+
+	return nil
+}
+
+func (o *oInterface) implementedby(x obj) bool {
+	return o.check(x) == nil
+}
@@ -4,10 +4,13 @@ import "container/list"
 
 // iterator is an object which has multiple values
 // which can be looped over them.
+// Creating the iterator can fail, such as calling Iter method of the struct,
+// but once it is created, next never fails.
 type iterator interface {
 	size() int
 	hasnext() bool
-	next() (*obj, int)
+	// next returns the next value and its index.
+	next() (obj, int)
 }
 
 type strIterator struct {
@@ -23,15 +26,15 @@ func (i *strIterator) hasnext() bool {
 	return i.i < len(i.runes)
 }
 
-func (i *strIterator) next() (*obj, int) {
+func (i *strIterator) next() (obj, int) {
 	idx := i.i
-	o := &obj{typ: tStr, bytes: []byte(string(i.runes[idx]))}
+	o := newstr(string(i.runes[idx]))
 	i.i++
 	return o, idx
 }
 
 type listIterator struct {
-	vals []*obj
+	vals []obj
 	i    int
 }
 
@@ -43,7 +46,7 @@ func (i *listIterator) hasnext() bool {
 	return i.i < len(i.vals)
 }
 
-func (i *listIterator) next() (*obj, int) {
+func (i *listIterator) next() (obj, int) {
 	idx := i.i
 	o := i.vals[idx]
 	i.i++
@@ -57,18 +60,39 @@ type dictIterator struct {
 }
 
 func (i *dictIterator) size() int {
-	return i.d.keys.Len()
+	return i.d.size()
 }
 
 func (i *dictIterator) hasnext() bool {
 	return i.e != nil
 }
 
-func (i *dictIterator) next() (*obj, int) {
-	retkey := i.e.Value.(objkey) // this is objkey(string)
-	retk := i.d.kk[retkey]       // extract key obj by objkey
+func (i *dictIterator) next() (obj, int) {
+	retk := i.e.Value.(*dictentry).k
 	retidx := i.i
 	i.e = i.e.Next()
 	i.i++
 	return retk, retidx // return key obj when iterating dict
 }
+
+type rangeIterator struct {
+	r   *oRange
+	cur int64
+	i   int
+}
+
+func (i *rangeIterator) size() int {
+	return i.r.size()
+}
+
+func (i *rangeIterator) hasnext() bool {
+	return i.i < i.r.size()
+}
+
+func (i *rangeIterator) next() (obj, int) {
+	o := &oI64{val: i.cur}
+	idx := i.i
+	i.cur += i.r.step
+	i.i++
+	return o, idx
+}
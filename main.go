@@ -42,10 +42,10 @@ func run(args []string) int {
 		return 0
 	}
 
-	env = &environment{modules: map[string]*module{}}
+	env := newenvironment(os.Stdout, os.Stderr)
 
 	if len(args) <= 1 {
-		return repl()
+		return repl(env)
 	}
 
 	a1 := args[1]
@@ -54,7 +54,7 @@ func run(args []string) int {
 		return 1
 	}
 
-	return interpret(a1)
+	return interpret(env, a1)
 }
 
 func showversion() {
@@ -0,0 +1,873 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// builtinMethod is a method of built-in type such as str.
+// recv is the receiver, whose type is the same as the type which has the method.
+// kw is checked against methodKwnames before the method is called.
+type builtinMethod func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error)
+
+// typeMethods is the methods of the built-in types keyed by the type name.
+var typeMethods = map[string]map[string]builtinMethod{
+	"str":  strMethods,
+	"list": listMethods,
+	"dict": dictMethods,
+	"set":  setMethods,
+}
+
+// methodKwnames is the names of the keyword args which the built-in methods accept, keyed by "type.method".
+// The methods not listed here accept no keyword args.
+var methodKwnames = map[string][]string{
+	"list.sort": {"key", "reverse"},
+}
+
+// builtinmethods returns the methods of the built-in type, or nil if the type has no methods.
+func builtinmethods(recv obj) map[string]builtinMethod {
+	switch recv.(type) {
+	case *oStr, *oList, *oDict, *oSet:
+		return typeMethods[recv.typename()]
+	}
+
+	return nil
+}
+
+// getbuiltinmethod returns the method of the built-in type bound to the receiver.
+func getbuiltinmethod(recv obj, name string) (obj, bool) {
+	m, ok := builtinmethods(recv)[name]
+	if !ok {
+		return nil, false
+	}
+
+	fn := recv.typename() + "." + name
+	return &oBuiltinFunc{
+		name: fn,
+		body: func(env *environment, kw *kwargs, args ...obj) (obj, error) {
+			return m(env, recv, kw, args...)
+		},
+		kwnames: methodKwnames[fn],
+	}, true
+}
+
+// argstr returns args[i] as str, or error if it is not str.
+func argstr(name string, args []obj, i int) (string, error) {
+	s, ok := args[i].(*oStr)
+	if !ok {
+		return "", fmt.Errorf("%s() arg %d must be str but got %s", name, i+1, args[i].typename())
+	}
+
+	return s.String(), nil
+}
+
+// argi64 returns args[i] as int, or error if it is not i64.
+func argi64(name string, args []obj, i int) (int, error) {
+	n, ok := args[i].(*oI64)
+	if !ok {
+		return 0, fmt.Errorf("%s() arg %d must be i64 but got %s", name, i+1, args[i].typename())
+	}
+
+	return int(n.val), nil
+}
+
+// checkargs returns error if the number of args is not between min and max.
+func checkargs(name string, args []obj, min, max int) error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return fmt.Errorf("argument mismatch to %s(): %d args required", name, min)
+		}
+		return fmt.Errorf("argument mismatch to %s(): %d to %d args required", name, min, max)
+	}
+
+	return nil
+}
+
+func strlist(ss []string) *oList {
+	l := &oList{vals: make([]obj, len(ss))}
+	for i, s := range ss {
+		l.vals[i] = newstr(s)
+	}
+	return l
+}
+
+/*
+ * str methods
+ */
+
+var strMethods = map[string]builtinMethod{
+	"bytes": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("bytes", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		l := &oList{}
+		for _, b := range recv.(*oStr).val {
+			l.vals = append(l.vals, &oI64{val: int64(b)})
+		}
+		return l, nil
+	},
+	"contains": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("contains", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		sub, err := argstr("contains", args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return newbool(strings.Contains(recv.String(), sub)), nil
+	},
+	"count": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("count", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		sub, err := argstr("count", args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return &oI64{val: int64(strings.Count(recv.String(), sub))}, nil
+	},
+	"endswith": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("endswith", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		suffix, err := argstr("endswith", args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return newbool(strings.HasSuffix(recv.String(), suffix)), nil
+	},
+	"find": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("find", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		sub, err := argstr("find", args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return &oI64{val: int64(runeindex(recv.String(), sub))}, nil
+	},
+	"index": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("index", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		sub, err := argstr("index", args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		i := runeindex(recv.String(), sub)
+		if i < 0 {
+			return nil, fmt.Errorf("substring %q is not found", sub)
+		}
+		return &oI64{val: int64(i)}, nil
+	},
+	"join": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("join", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		if !args[0].isIterable() {
+			return nil, fmt.Errorf("join() arg must be iterable but got %s", args[0].typename())
+		}
+
+		it, err := args[0].iterator()
+		if err != nil {
+			return nil, err
+		}
+
+		ss := []string{}
+		for it.hasnext() {
+			o, i := it.next()
+			s, ok := o.(*oStr)
+			if !ok {
+				return nil, fmt.Errorf("join() requires str elements but got %s at %d", o.typename(), i)
+			}
+			ss = append(ss, s.String())
+		}
+
+		return newstr(strings.Join(ss, recv.String())), nil
+	},
+	"lines": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("lines", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		s := recv.String()
+		if s == "" {
+			return &oList{}, nil
+		}
+
+		// the last line break does not make an empty line
+		lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+		for i, l := range lines {
+			lines[i] = strings.TrimSuffix(l, "\r")
+		}
+		return strlist(lines), nil
+	},
+	"lower": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("lower", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		return newstr(strings.ToLower(recv.String())), nil
+	},
+	"ltrim": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		return strtrim("ltrim", strings.TrimLeft, strings.TrimLeftFunc, recv, args)
+	},
+	"repeat": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("repeat", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		n, err := argi64("repeat", args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if n < 0 {
+			return nil, fmt.Errorf("repeat() count must not be negative")
+		}
+		return recv.(*oStr).repeat(n)
+	},
+	"replace": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("replace", args, 2, 3); err != nil {
+			return nil, err
+		}
+
+		old, err := argstr("replace", args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		to, err := argstr("replace", args, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		// replace all by default
+		n := -1
+		if len(args) == 3 {
+			if n, err = argi64("replace", args, 2); err != nil {
+				return nil, err
+			}
+		}
+
+		return newstr(strings.Replace(recv.String(), old, to, n)), nil
+	},
+	"rtrim": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		return strtrim("rtrim", strings.TrimRight, strings.TrimRightFunc, recv, args)
+	},
+	"runes": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("runes", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		l := &oList{}
+		for _, r := range recv.String() {
+			l.vals = append(l.vals, &oI64{val: int64(r)})
+		}
+		return l, nil
+	},
+	"split": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("split", args, 0, 2); err != nil {
+			return nil, err
+		}
+
+		// without separator, split by whitespaces
+		if len(args) == 0 {
+			return strlist(strings.Fields(recv.String())), nil
+		}
+
+		sep, err := argstr("split", args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if sep == "" {
+			return nil, fmt.Errorf("split() separator must not be empty")
+		}
+
+		n := -1
+		if len(args) == 2 {
+			max, err := argi64("split", args, 1)
+			if err != nil {
+				return nil, err
+			}
+			// max is the number of splits like Python
+			if max >= 0 {
+				n = max + 1
+			}
+		}
+
+		return strlist(strings.SplitN(recv.String(), sep, n)), nil
+	},
+	"startswith": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("startswith", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		prefix, err := argstr("startswith", args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return newbool(strings.HasPrefix(recv.String(), prefix)), nil
+	},
+	"trim": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		return strtrim("trim", strings.Trim, strings.TrimFunc, recv, args)
+	},
+	"trimprefix": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("trimprefix", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		prefix, err := argstr("trimprefix", args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return newstr(strings.TrimPrefix(recv.String(), prefix)), nil
+	},
+	"trimsuffix": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("trimsuffix", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		suffix, err := argstr("trimsuffix", args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return newstr(strings.TrimSuffix(recv.String(), suffix)), nil
+	},
+	"upper": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("upper", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		return newstr(strings.ToUpper(recv.String())), nil
+	},
+}
+
+// strtrim trims the characters in the cutset given as the arg, or whitespaces if it is omitted.
+func strtrim(name string, trim func(string, string) string, trimfunc func(string, func(rune) bool) string, recv obj, args []obj) (obj, error) {
+	if err := checkargs(name, args, 0, 1); err != nil {
+		return nil, err
+	}
+
+	if len(args) == 0 {
+		return newstr(trimfunc(recv.String(), unicode.IsSpace)), nil
+	}
+
+	cutset, err := argstr(name, args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return newstr(trim(recv.String(), cutset)), nil
+}
+
+// runeindex returns the index of sub in s counted in runes, or -1.
+func runeindex(s, sub string) int {
+	i := strings.Index(s, sub)
+	if i < 0 {
+		return -1
+	}
+
+	return utf8.RuneCountInString(s[:i])
+}
+
+/*
+ * list methods
+ * Methods such as append() and sort() modify the list in place.
+ */
+
+var listMethods = map[string]builtinMethod{
+	"append": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		l := recv.(*oList)
+		l.vals = append(l.vals, args...)
+		return NIL, nil
+	},
+	"clear": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("clear", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		recv.(*oList).vals = []obj{}
+		return NIL, nil
+	},
+	"contains": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("contains", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		i, err := indexof(recv.(*oList).vals, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return newbool(i >= 0), nil
+	},
+	"copy": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("copy", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		return recv.clone(), nil
+	},
+	"count": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("count", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		n := 0
+		for _, v := range recv.(*oList).vals {
+			eq, err := v.equals(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			if eq {
+				n++
+			}
+		}
+		return &oI64{val: int64(n)}, nil
+	},
+	"extend": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("extend", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		vals, ok, err := elems(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("extend() arg must be iterable but got %s", args[0].typename())
+		}
+
+		l := recv.(*oList)
+		l.vals = append(l.vals, vals...)
+		return NIL, nil
+	},
+	"index": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("index", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		i, err := indexof(recv.(*oList).vals, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if i < 0 {
+			return nil, fmt.Errorf("%s is not in list", args[0])
+		}
+		return &oI64{val: int64(i)}, nil
+	},
+	"insert": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("insert", args, 2, 2); err != nil {
+			return nil, err
+		}
+
+		i, err := argi64("insert", args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// like Python, negative index counts from the end and index out of range inserts at either end
+		l := recv.(*oList)
+		if i < 0 {
+			i += len(l.vals)
+		}
+		if i < 0 {
+			i = 0
+		}
+		if i > len(l.vals) {
+			i = len(l.vals)
+		}
+
+		l.vals = append(l.vals[:i], append([]obj{args[1]}, l.vals[i:]...)...)
+		return NIL, nil
+	},
+	"pop": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("pop", args, 0, 1); err != nil {
+			return nil, err
+		}
+
+		l := recv.(*oList)
+		if len(l.vals) == 0 {
+			return nil, fmt.Errorf("pop from empty list")
+		}
+
+		// pop the last by default
+		i := len(l.vals) - 1
+		if len(args) == 1 {
+			var err error
+			if i, err = argi64("pop", args, 0); err != nil {
+				return nil, err
+			}
+
+			if i < 0 {
+				i += len(l.vals)
+			}
+
+			if i < 0 || len(l.vals) <= i {
+				return nil, fmt.Errorf("pop index out of range")
+			}
+		}
+
+		o := l.vals[i]
+		l.vals = append(l.vals[:i], l.vals[i+1:]...)
+		return o, nil
+	},
+	"remove": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("remove", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		l := recv.(*oList)
+		i, err := indexof(l.vals, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if i < 0 {
+			return nil, fmt.Errorf("%s is not in list", args[0])
+		}
+
+		l.vals = append(l.vals[:i], l.vals[i+1:]...)
+		return NIL, nil
+	},
+	"reverse": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("reverse", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		reverseobjs(recv.(*oList).vals)
+		return NIL, nil
+	},
+}
+
+/*
+ * dict methods
+ * The order of the keys is always the insertion order.
+ */
+
+var dictMethods = map[string]builtinMethod{
+	"clear": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("clear", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		recv.(*oDict).dict = newdict()
+		return NIL, nil
+	},
+	"copy": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("copy", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		return &oDict{dict: recv.(*oDict).dict.copy()}, nil
+	},
+	"delete": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("delete", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		// unlike "del d[k]", missing key is not an error. It returns if the key is deleted.
+		ok, err := recv.(*oDict).dict.del(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return newbool(ok), nil
+	},
+	"get": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("get", args, 1, 2); err != nil {
+			return nil, err
+		}
+
+		v, ok, err := recv.(*oDict).dict.get(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return v, nil
+		}
+
+		// the default is nil
+		if len(args) == 2 {
+			return args[1], nil
+		}
+		return NIL, nil
+	},
+	"has": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("has", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		_, ok, err := recv.(*oDict).dict.get(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return newbool(ok), nil
+	},
+	"items": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("items", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		d := recv.(*oDict).dict
+		l := &oList{vals: []obj{}}
+		for e := d.entries.Front(); e != nil; e = e.Next() {
+			de := e.Value.(*dictentry)
+			l.vals = append(l.vals, &oList{vals: []obj{de.k, de.v}})
+		}
+		return l, nil
+	},
+	"keys": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("keys", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		return &oList{vals: recv.(*oDict).dict.keys()}, nil
+	},
+	"pop": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("pop", args, 1, 2); err != nil {
+			return nil, err
+		}
+
+		d := recv.(*oDict).dict
+		v, ok, err := d.get(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			if len(args) == 2 {
+				return args[1], nil
+			}
+			return nil, fmt.Errorf("key %s is not found", args[0])
+		}
+
+		if _, err := d.del(args[0]); err != nil {
+			return nil, err
+		}
+		return v, nil
+	},
+	"update": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("update", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		x, ok := args[0].(*oDict)
+		if !ok {
+			return nil, fmt.Errorf("update() arg must be dict but got %s", args[0].typename())
+		}
+
+		if err := recv.(*oDict).dict.update(x.dict); err != nil {
+			return nil, err
+		}
+		return NIL, nil
+	},
+	"values": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("values", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		return &oList{vals: recv.(*oDict).dict.vals()}, nil
+	},
+}
+
+/*
+ * set methods
+ */
+
+var setMethods = map[string]builtinMethod{
+	"add": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("add", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		if err := recv.(*oSet).add(args[0]); err != nil {
+			return nil, err
+		}
+		return NIL, nil
+	},
+	"clear": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("clear", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		recv.(*oSet).dict = newdict()
+		return NIL, nil
+	},
+	"contains": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("contains", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		ok, err := recv.(*oSet).has(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return newbool(ok), nil
+	},
+	"copy": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("copy", args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		return &oSet{dict: recv.(*oSet).dict.copy()}, nil
+	},
+	"remove": func(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+		if err := checkargs("remove", args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		ok, err := recv.(*oSet).dict.del(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("%s is not in set", args[0])
+		}
+		return NIL, nil
+	},
+}
+
+// listsort is list.sort(). It is added to listMethods in init() in builtin.go.
+func listsort(env *environment, recv obj, kw *kwargs, args ...obj) (obj, error) {
+	if err := checkargs("sort", args, 0, 1); err != nil {
+		return nil, err
+	}
+
+	var key obj
+	if len(args) == 1 {
+		key = args[0]
+	}
+
+	if err := sortkw(env, "sort", recv.(*oList).vals, key, kw); err != nil {
+		return nil, err
+	}
+	return NIL, nil
+}
+
+// reverseobjs reverses vals in place.
+func reverseobjs(vals []obj) {
+	for i, j := 0, len(vals)-1; i < j; i, j = i+1, j-1 {
+		vals[i], vals[j] = vals[j], vals[i]
+	}
+}
+
+// indexof returns the index of the first element which equals o, or -1.
+func indexof(vals []obj, o obj) (int, error) {
+	for i, v := range vals {
+		eq, err := v.equals(o)
+		if err != nil {
+			return 0, err
+		}
+
+		if eq {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// sortkw sorts vals in place following the "key" and "reverse" keyword args of sorted() and list.sort().
+// key is the key function given as a positional arg, or nil.
+func sortkw(env *environment, name string, vals []obj, key obj, kw *kwargs) error {
+	if k, ok := kw.get("key"); ok {
+		if key != nil {
+			return fmt.Errorf("multiple values for argument key to %s()", name)
+		}
+		key = k
+	}
+
+	reverse := false
+	if o, ok := kw.get("reverse"); ok {
+		reverse = o.isTruethy()
+	}
+
+	if !reverse {
+		return sortobjs(env, vals, key)
+	}
+
+	// reversing before and after the stable sort keeps the order of the equal elements.
+	// The copy is sorted so that vals is not left reversed on error.
+	rev := make([]obj, len(vals))
+	copy(rev, vals)
+	reverseobjs(rev)
+	if err := sortobjs(env, rev, key); err != nil {
+		return err
+	}
+
+	reverseobjs(rev)
+	copy(vals, rev)
+	return nil
+}
+
+// sortobjs sorts vals in ascending order using "<" operator. The sort is stable.
+// If key is not nil, it is called with each element, and the results are compared instead.
+func sortobjs(env *environment, vals []obj, key obj) error {
+	keys := vals
+	if key != nil {
+		keys = make([]obj, len(vals))
+		for i, v := range vals {
+			k, err := env.call(key, v)
+			if err != nil {
+				return err
+			}
+			keys[i] = k
+		}
+	}
+
+	// sort indices so that vals and keys are sorted together
+	idx := make([]int, len(vals))
+	for i := range idx {
+		idx[i] = i
+	}
+
+	var err error
+	sort.SliceStable(idx, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+
+		var less obj
+		less, err = computeBinaryOp(keys[idx[i]], keys[idx[j]], boLess)
+		if err != nil {
+			return false
+		}
+
+		return less.isTruethy()
+	})
+	if err != nil {
+		return err
+	}
+
+	sorted := make([]obj, len(vals))
+	for i, j := range idx {
+		sorted[i] = vals[j]
+	}
+	copy(vals, sorted)
+
+	return nil
+}
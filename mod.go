@@ -50,6 +50,15 @@ import (
  * }
  * ```
  * In both f1 and f2, the global var a should be visible. Note that f2 is called from f1, but b in f1 must not be visible from f2.
+ *
+ * The function scope of a method has the receiver. The receiver is visible as self, or as the name given like "def (p) f()".
+ * The fields and methods of the receiver, including the promoted ones, are visible after the local variables
+ * and before the global ones. Assigning to the field name updates the receiver itself, so the change is seen
+ * by the other methods immediately.
+ *
+ * As for the values, assignment shares the obj; after "b = a", modifying list, dict or struct b also modifies a.
+ * On the other hand, the args of a function call are copied, so the function cannot modify the caller's values.
+ * The only exception is the receiver of a method, which is shared so that the method can modify it.
  */
 type module struct {
 	name       string
@@ -60,8 +69,10 @@ type module struct {
 	funcscopes *list.List
 }
 
-func (m *module) createfuncscope() {
-	m.funcscopes.PushBack(newscope())
+func (m *module) createfuncscope(recv *oStruct) {
+	s := newscope()
+	s.recv = recv
+	m.funcscopes.PushBack(s)
 }
 
 func (m *module) delfuncscope() {
@@ -79,22 +90,40 @@ func (m *module) createblockscope() {
 
 func (m *module) delblockscope() {
 	if m.funcscopes.Len() != 0 {
-		m.funcscopes.Back().Value.(*scope).addblockscope()
+		m.funcscopes.Back().Value.(*scope).delblockscope()
 		return
 	}
 
 	m.globscope.delblockscope()
 }
 
-func (m *module) setobj(name string, o *obj) {
+func (m *module) setobj(name string, o obj) {
 	if m.funcscopes.Len() != 0 {
-		m.funcscopes.Back().Value.(*scope).setobj(name, o)
+		fs := m.funcscopes.Back().Value.(*scope)
+		// global variable is visible in a function, so it can be updated from the function.
+		if !fs.hasobj(name) {
+			if _, ok := m.globscope.getglobobj(name); ok {
+				m.globscope.objs[name] = o
+				return
+			}
+		}
+
+		fs.setobj(name, o)
 		return
 	}
 
 	m.globscope.setobj(name, o)
 }
 
+func (m *module) defobj(name string, o obj) {
+	if m.funcscopes.Len() != 0 {
+		m.funcscopes.Back().Value.(*scope).defobj(name, o)
+		return
+	}
+
+	m.globscope.defobj(name, o)
+}
+
 func (m *module) setstruct(name string, s *structdef) {
 	if m.funcscopes.Len() != 0 {
 		m.funcscopes.Back().Value.(*scope).setstruct(name, s)
@@ -104,7 +133,7 @@ func (m *module) setstruct(name string, s *structdef) {
 	m.globscope.setstruct(name, s)
 }
 
-func (m *module) getobj(name string) (*obj, bool) {
+func (m *module) getobj(name string) (obj, bool) {
 	if m.funcscopes.Len() != 0 {
 		o, ok := m.funcscopes.Back().Value.(*scope).getobj(name)
 		if ok {
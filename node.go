@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 	"unicode"
 )
 
@@ -87,6 +88,12 @@ func (bo binaryOp) String() string {
 		return "<<"
 	case boRightShift:
 		return ">>"
+	case boCoalesce:
+		return "??"
+	case boIn:
+		return "in"
+	case boNotIn:
+		return "not in"
 	default:
 		return "?"
 	}
@@ -115,6 +122,11 @@ const (
 
 	boLeftShift
 	boRightShift
+
+	boCoalesce
+
+	boIn
+	boNotIn
 )
 
 type unaryOp int
@@ -199,37 +211,87 @@ func (n *ndIf) String() string {
 	return fmt.Sprintf("ndIf{conds: %s, blocks: %s}", nodesToStr(n.conds), bs)
 }
 
+type ndTry struct {
+	tok    *token
+	blocks []node
+	// variable name which the error message is assigned to. nil if omitted.
+	errname     node
+	catchblocks []node
+}
+
+func (n *ndTry) token() *token { return n.tok }
+func (n *ndTry) isexported() bool { return false }
+func (n *ndTry) String() string {
+	return fmt.Sprintf("ndTry{blocks: %s, errname: %v, catchblocks: %s}", nodesToStr(n.blocks), n.errname, nodesToStr(n.catchblocks))
+}
+
+// ndMatch runs the block of the first case whose pattern matches the target and guard is truthy.
+type ndMatch struct {
+	tok    *token
+	target node
+	cases  []*ndCase
+}
+
+func (n *ndMatch) token() *token { return n.tok }
+func (n *ndMatch) isexported() bool { return false }
+func (n *ndMatch) String() string {
+	cs := "["
+	for _, c := range n.cases {
+		cs += c.String() + ","
+	}
+	cs += "]"
+	return fmt.Sprintf("ndMatch{target: %s, cases: %s}", n.target, cs)
+}
+
+type ndCase struct {
+	tok     *token
+	pattern node
+	// guard is nil if omitted.
+	guard  node
+	blocks []node
+}
+
+func (n *ndCase) token() *token { return n.tok }
+func (n *ndCase) isexported() bool { return false }
+func (n *ndCase) String() string {
+	return fmt.Sprintf("ndCase{pattern: %s, guard: %v, blocks: %s}", n.pattern, n.guard, nodesToStr(n.blocks))
+}
+
 type ndLoop struct {
 	tok *token
 	// loop target, something iterable
 	target node
-	// counter, element var name
+	// counter, element var name. counter is nil in "for x in target".
 	cnt    node
 	elem   node
 	blocks []node
+	label  string
 }
 
 func (n *ndLoop) token() *token { return n.tok }
 func (n *ndLoop) isexported() bool { return false }
 func (n *ndLoop) String() string {
-	return fmt.Sprintf("ndLoop{target: %s, cnt: %s, elem: %s, blocks: %s}", n.target, n.cnt, n.elem, nodesToStr(n.blocks))
+	return fmt.Sprintf("ndLoop{target: %s, cnt: %s, elem: %s, label: %s, blocks: %s}", n.target, n.cnt, n.elem, n.label, nodesToStr(n.blocks))
 }
 
+// ndCondLoop loops while cond is truthy. nil cond loops forever.
 type ndCondLoop struct {
 	tok    *token
 	cond   node
 	blocks []node
+	label  string
 }
 
 func (n *ndCondLoop) token() *token { return n.tok }
 func (n *ndCondLoop) isexported() bool { return false }
 func (n *ndCondLoop) String() string {
-	return fmt.Sprintf("ndCondLoop{cond: %s, blocks: %s}", n.cond, nodesToStr(n.blocks))
+	return fmt.Sprintf("ndCondLoop{cond: %s, label: %s, blocks: %s}", n.cond, n.label, nodesToStr(n.blocks))
 }
 
 type ndFunDef struct {
 	tok    *token
 	name   string
+	recv   string // receiver name of the struct method, empty if not named
 	params []node
 	blocks []node
 }
@@ -269,12 +331,14 @@ type ndSelector struct {
 	tok      *token
 	selector node
 	target   node
+	// true when "?.", which results in nil if the selector is nil.
+	optional bool
 }
 
 func (n *ndSelector) token() *token { return n.tok }
 func (n *ndSelector) isexported() bool { return n.target.isexported() }
 func (n *ndSelector) String() string {
-	return fmt.Sprintf("ndSelector{selector: %s, target: %s}", n.selector, n.target)
+	return fmt.Sprintf("ndSelector{selector: %s, target: %s, optional: %t}", n.selector, n.target, n.optional)
 }
 
 type ndIndex struct {
@@ -289,17 +353,19 @@ func (n *ndIndex) String() string {
 	return fmt.Sprintf("ndIndex{idx: %s, target: %s}", n.idx, n.target)
 }
 
+// ndSlice is target[start:end:step]. Omitted start, end or step is nil.
 type ndSlice struct {
 	tok    *token
 	start  node
 	end    node
+	step   node
 	target node
 }
 
 func (n *ndSlice) token() *token { return n.tok }
 func (n *ndSlice) isexported() bool { return n.target.isexported() }
 func (n *ndSlice) String() string {
-	return fmt.Sprintf("ndSlice{start: %s, end: %s, target: %s}", n.start, n.end, n.target)
+	return fmt.Sprintf("ndSlice{start: %s, end: %s, step: %s, target: %s}", n.start, n.end, n.step, n.target)
 }
 
 type ndFuncall struct {
@@ -314,6 +380,50 @@ func (n *ndFuncall) String() string {
 	return fmt.Sprintf("ndFuncall{fn: %s, args: %s}", n.fn, nodesToStr(n.args))
 }
 
+// ndParam is a function parameter. "name=dflt" has a default value,
+// "*name" collects the rest positional args and "**name" collects the rest keyword args.
+type ndParam struct {
+	tok        *token
+	name       string
+	annot      node // interface which the arg must implement
+	dflt       node
+	variadic   bool
+	kwvariadic bool
+}
+
+func (n *ndParam) token() *token { return n.tok }
+func (n *ndParam) isexported() bool { return false }
+func (n *ndParam) String() string {
+	return fmt.Sprintf("ndParam{name: %s, annot: %v, dflt: %v, variadic: %v, kwvariadic: %v}", n.name, n.annot, n.dflt, n.variadic, n.kwvariadic)
+}
+
+// ndKwArg is the keyword argument "name=val" in a function call.
+type ndKwArg struct {
+	tok  *token
+	name string
+	val  node
+}
+
+func (n *ndKwArg) token() *token { return n.tok }
+func (n *ndKwArg) isexported() bool { return false }
+func (n *ndKwArg) String() string {
+	return fmt.Sprintf("ndKwArg{name: %s, val: %s}", n.name, n.val)
+}
+
+// ndSpread is "*target" or "**target" in a function call.
+// The former spreads the iterable as positional args, the latter spreads the dict as keyword args.
+type ndSpread struct {
+	tok    *token
+	target node
+	kw     bool
+}
+
+func (n *ndSpread) token() *token { return n.tok }
+func (n *ndSpread) isexported() bool { return false }
+func (n *ndSpread) String() string {
+	return fmt.Sprintf("ndSpread{target: %s, kw: %v}", n.target, n.kw)
+}
+
 type ndIdent struct {
 	tok   *token
 	ident string
@@ -336,6 +446,31 @@ func (n *ndStr) String() string {
 	return fmt.Sprintf("ndStr{val: %s}", n.val)
 }
 
+type ndFStr struct {
+	tok *token
+	// ndStr or ndFormat
+	parts []node
+}
+
+func (n *ndFStr) token() *token { return n.tok }
+func (n *ndFStr) isexported() bool { return true }
+func (n *ndFStr) String() string {
+	return fmt.Sprintf("ndFStr{parts: %s}", nodesToStr(n.parts))
+}
+
+// ndFormat formats the target as the spec into str.
+type ndFormat struct {
+	tok    *token
+	target node
+	spec   *fmtspec
+}
+
+func (n *ndFormat) token() *token { return n.tok }
+func (n *ndFormat) isexported() bool { return true }
+func (n *ndFormat) String() string {
+	return fmt.Sprintf("ndFormat{target: %s, spec: %+v}", n.target, *n.spec)
+}
+
 type ndI64 struct {
 	tok *token
 	val int64
@@ -347,6 +482,17 @@ func (n *ndI64) String() string {
 	return fmt.Sprintf("ndI64{val: %d}", n.val)
 }
 
+type ndBigInt struct {
+	tok *token
+	val *big.Int
+}
+
+func (n *ndBigInt) token() *token { return n.tok }
+func (n *ndBigInt) isexported() bool { return true }
+func (n *ndBigInt) String() string {
+	return fmt.Sprintf("ndBigInt{val: %s}", n.val)
+}
+
 type ndF64 struct {
 	tok *token
 	val float64
@@ -358,6 +504,14 @@ func (n *ndF64) String() string {
 	return fmt.Sprintf("ndF64{val: %f}", n.val)
 }
 
+type ndNil struct {
+	tok *token
+}
+
+func (n *ndNil) token() *token { return n.tok }
+func (n *ndNil) isexported() bool { return true }
+func (n *ndNil) String() string   { return "ndNil{}" }
+
 type ndBool struct {
 	tok *token
 	val bool
@@ -369,6 +523,28 @@ func (n *ndBool) String() string {
 	return fmt.Sprintf("ndBool{val: %t}", n.val)
 }
 
+type ndTuple struct {
+	tok  *token
+	vals []node
+}
+
+func (n *ndTuple) token() *token { return n.tok }
+func (n *ndTuple) isexported() bool { return true }
+func (n *ndTuple) String() string {
+	return fmt.Sprintf("ndTuple{vals: %s}", nodesToStr(n.vals))
+}
+
+type ndSet struct {
+	tok  *token
+	vals []node
+}
+
+func (n *ndSet) token() *token { return n.tok }
+func (n *ndSet) isexported() bool { return true }
+func (n *ndSet) String() string {
+	return fmt.Sprintf("ndSet{vals: %s}", nodesToStr(n.vals))
+}
+
 type ndList struct {
 	tok  *token
 	vals []node
@@ -405,6 +581,45 @@ func (n *ndStructDef) String() string {
 	return fmt.Sprintf("ndStructDef{name: %s, vars: %s, fns: %s}", n.name, nodesToStr(n.vars), nodesToStr(n.fns))
 }
 
+// ndInterfaceDef is the interface declaration. fns are ndFunDef without blocks.
+type ndInterfaceDef struct {
+	tok  *token
+	name node
+	fns  []node
+}
+
+func (n *ndInterfaceDef) token() *token { return n.tok }
+func (n *ndInterfaceDef) isexported() bool { return n.name.isexported() }
+func (n *ndInterfaceDef) String() string {
+	return fmt.Sprintf("ndInterfaceDef{name: %s, fns: %s}", n.name, nodesToStr(n.fns))
+}
+
+// ndEnumDef is the enum declaration.
+type ndEnumDef struct {
+	tok      *token
+	name     node
+	variants []*ndVariant
+}
+
+func (n *ndEnumDef) token() *token { return n.tok }
+func (n *ndEnumDef) isexported() bool { return n.name.isexported() }
+func (n *ndEnumDef) String() string {
+	return fmt.Sprintf("ndEnumDef{name: %s, variants: %v}", n.name, n.variants)
+}
+
+// ndVariant is the variant of the enum. fields are the names of the payload.
+type ndVariant struct {
+	tok    *token
+	name   string
+	fields []string
+}
+
+func (n *ndVariant) token() *token { return n.tok }
+func (n *ndVariant) isexported() bool { return isexported(n.name) }
+func (n *ndVariant) String() string {
+	return fmt.Sprintf("ndVariant{name: %s, fields: %v}", n.name, n.fields)
+}
+
 type ndStructInit struct {
 	tok    *token
 	name   node
@@ -418,23 +633,25 @@ func (n *ndStructInit) String() string {
 }
 
 type ndContinue struct {
-	tok *token
+	tok   *token
+	label string
 }
 
 func (n *ndContinue) token() *token { return n.tok }
 func (n *ndContinue) isexported() bool { return false }
 func (n *ndContinue) String() string {
-	return "ndContinue{}"
+	return fmt.Sprintf("ndContinue{label: %s}", n.label)
 }
 
 type ndBreak struct {
-	tok *token
+	tok   *token
+	label string
 }
 
 func (n *ndBreak) token() *token { return n.tok }
 func (n *ndBreak) isexported() bool { return false }
 func (n *ndBreak) String() string {
-	return "ndBreak{}"
+	return fmt.Sprintf("ndBreak{label: %s}", n.label)
 }
 
 type ndReturn struct {
@@ -448,6 +665,18 @@ func (n *ndReturn) String() string {
 	return fmt.Sprintf("ndReturn{val: %s}", n.val)
 }
 
+type ndDel struct {
+	tok *token
+	// ndIndex to be deleted
+	targets []node
+}
+
+func (n *ndDel) token() *token { return n.tok }
+func (n *ndDel) isexported() bool { return false }
+func (n *ndDel) String() string {
+	return fmt.Sprintf("ndDel{targets: %s}", nodesToStr(n.targets))
+}
+
 type ndImport struct {
 	tok    *token
 	target string
@@ -1,110 +1,171 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
+// obj is a value in shiba.
+// Every type of value implements obj, so adding a new type is done by
+// defining the type and its methods; the interpreter does not need to know it.
 type obj interface {
-	key() objkey
-	clone() *obj
+	// typename returns the name of the type.
+	typename() string
+	// hash returns the hash to be used when the obj is used as a dict key.
+	// The equal objs must have the same hash. It fails if the obj cannot be a dict key.
+	hash() (uint64, error)
+	clone() obj
 	isTruethy() bool
-	equals(x *obj) bool
+	// equals reports if o equals x. It fails if Eq method of the struct fails.
+	equals(x obj) (bool, error)
 	isIterable() bool
-	iterator() iterator
+	// iterator returns the iterator of the iterable obj. It fails if Iter method of the struct fails.
+	iterator() (iterator, error)
 	isSequencable() bool
 	sequence() sequence
+	// binaryop computes "o op x". It returns nil obj (and nil error)
+	// if the operation is not defined between o and x.
+	binaryop(op binaryOp, x obj) (obj, error)
+	// unaryop computes "op o". It returns nil if the operation is not defined on o.
+	unaryop(op unaryOp) obj
+	fmt.Stringer
 }
 
-type nonIterable struct {}
-func (*nonIterable) isIterable() bool { return false }
-func (*nonIterable) iterator() iterator{ panic("iterator() is called on non iterable obj") }
+type nonIterable struct{}
+
+func (*nonIterable) isIterable() bool            { return false }
+func (*nonIterable) iterator() (iterator, error) { panic("iterator() is called on non iterable obj") }
+
+type nonSequencable struct{}
 
-type nonSequencable struct {}
 func (*nonSequencable) isSequencable() bool { return false }
-func (*nonSequencable) sequence() sequence { panic("sequence() is called on non sequencable obj") }
+func (*nonSequencable) sequence() sequence  { panic("sequence() is called on non sequencable obj") }
+
+type nonBinaryOperable struct{}
+
+func (*nonBinaryOperable) binaryop(op binaryOp, x obj) (obj, error) { return nil, nil }
 
-// type objkey string
-// 
-// func (o *obj) toObjKey() objkey {
-// 	return objkey(fmt.Sprintf("%s_%s", o.typ, o))
-// }
+type nonUnaryOperable struct{}
+
+func (*nonUnaryOperable) unaryop(op unaryOp) obj { return nil }
 
 var NIL = &oNil{}
 var TRUE = &oBool{val: true}
 var FALSE = &oBool{val: false}
 
-// type objtyp int
-// 
-// const (
-// 	tNil objtyp = iota
-// 	tBool
-// 	tF64
-// 	tI64
-// 	tStr
-// 	tList
-// 	tDict
-// 	tStruct
-// 	tBuiltinFunc
-// 	tGoStdModFunc
-// 	tFunc
-// 	tMethod
-// 	tMod
-// )
-
-// func (o objtyp) String() string {
-// 	switch o {
-// 	case tNil:
-// 		return "nil"
-// 	case tBool:
-// 		return "bool"
-// 	case tF64:
-// 		return "f64"
-// 	case tI64:
-// 		return "i64"
-// 	case tStr:
-// 		return "str"
-// 	case tList:
-// 		return "list"
-// 	case tDict:
-// 		return "dict"
-// 	case tStruct:
-// 		return "struct"
-// 	case tBuiltinFunc:
-// 		return "builtinfunc"
-// 	case tGoStdModFunc:
-// 		return "gostdmodfunc"
-// 	case tFunc:
-// 		return "func"
-// 	case tMethod:
-// 		return "method"
-// 	case tMod:
-// 		return "module"
-// 	}
-// 	return "?"
-// }
-
-type oNil struct {
-	nonIterable
-	nonSequencable
+func newbool(b bool) *oBool {
+	if b {
+		return TRUE
+	}
+	return FALSE
 }
 
-func (o *oNil) key() objkey {
-	return "nil"
+// computeBinaryOp computes "l op r".
+// Equality is defined between any objects, other operators are delegated to the left operand.
+func computeBinaryOp(l, r obj, op binaryOp) (obj, error) {
+	if op == boEq || op == boNotEq {
+		eq, err := l.equals(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return newbool(eq == (op == boEq)), nil
+	}
+
+	if op == boIn || op == boNotIn {
+		in, err := contains(r, l)
+		if err != nil {
+			return nil, err
+		}
+
+		return newbool(in == (op == boIn)), nil
+	}
+
+	if op == boCoalesce {
+		if _, ok := l.(*oNil); ok {
+			return r, nil
+		}
+		return l, nil
+	}
+
+	o, err := l.binaryop(op, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if o == nil {
+		return nil, fmt.Errorf("cannot compute: %s %s %s", l, op, r)
+	}
+
+	return o, nil
 }
 
-func (o *oNil) clone() *obj {
-	o2 := &oNil{}
-	return &o2
+// contains reports if o is in the container.
+// o is a key for dict and a substring for str, otherwise o is compared with every element of the iterable container.
+func contains(container, o obj) (bool, error) {
+	switch c := container.(type) {
+	case *oDict:
+		_, ok, err := c.dict.get(o)
+		return ok, err
+
+	case *oSet:
+		return c.has(o)
+
+	case *oStr:
+		s, ok := o.(*oStr)
+		if !ok {
+			return false, fmt.Errorf("left operand of \"in\" str must be str but got %s", o.typename())
+		}
+
+		return bytes.Contains(c.val, s.val), nil
+	}
+
+	if !container.isIterable() {
+		return false, fmt.Errorf("%s is not iterable", container.typename())
+	}
+
+	it, err := container.iterator()
+	if err != nil {
+		return false, err
+	}
+
+	for it.hasnext() {
+		e, _ := it.next() // the index is not used
+		eq, err := e.equals(o)
+		if err != nil || eq {
+			return eq, err
+		}
+	}
+
+	return false, nil
 }
 
-func (o *oNil) isTruethy() bool { return false }
+/*
+ * nil
+ */
 
-func (o *oNil) equals(x *obj) bool {
-	_, ok := *x.(*oNil)
-	return ok
+type oNil struct {
+	nonIterable
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
 }
 
+func (o *oNil) typename() string           { return "nil" }
+func (o *oNil) hash() (uint64, error)      { return hashuint("nil", 0), nil }
+func (o *oNil) clone() obj                 { return o }
+func (o *oNil) isTruethy() bool            { return false }
+func (o *oNil) String() string             { return "nil" }
+func (o *oNil) equals(x obj) (bool, error) { _, ok := x.(*oNil); return ok, nil }
+
+/*
+ * bool
+ */
+
 type oBool struct {
 	nonIterable
 	nonSequencable
@@ -112,13 +173,51 @@ type oBool struct {
 	val bool
 }
 
-type oF64 struct {
-	nonIterable
-	nonSequencable
+func (o *oBool) typename() string { return "bool" }
+func (o *oBool) clone() obj       { return o }
+func (o *oBool) isTruethy() bool  { return o.val }
+func (o *oBool) String() string   { return fmt.Sprintf("%t", o.val) }
 
-	val float64
+func (o *oBool) hash() (uint64, error) {
+	if o.val {
+		return hashuint("bool", 1), nil
+	}
+	return hashuint("bool", 0), nil
+}
+
+func (o *oBool) equals(x obj) (bool, error) {
+	xb, ok := x.(*oBool)
+	return ok && o.val == xb.val, nil
+}
+
+func (o *oBool) binaryop(op binaryOp, x obj) (obj, error) {
+	xb, ok := x.(*oBool)
+	if !ok {
+		return nil, nil
+	}
+
+	switch op {
+	case boLogicalOr:
+		return newbool(o.val || xb.val), nil
+	case boLogicalAnd:
+		return newbool(o.val && xb.val), nil
+	}
+
+	return nil, nil
+}
+
+func (o *oBool) unaryop(op unaryOp) obj {
+	if op == uoLogicalNot {
+		return newbool(!o.val)
+	}
+
+	return nil
 }
 
+/*
+ * i64
+ */
+
 type oI64 struct {
 	nonIterable
 	nonSequencable
@@ -126,636 +225,1348 @@ type oI64 struct {
 	val int64
 }
 
-type oStr struct {
-	nonIterable
-	nonSequencable
+func (o *oI64) typename() string      { return "i64" }
+func (o *oI64) hash() (uint64, error) { return hashuint("i64", uint64(o.val)), nil }
+func (o *oI64) clone() obj            { return o }
+func (o *oI64) isTruethy() bool       { return o.val != 0 }
+func (o *oI64) String() string        { return fmt.Sprintf("%d", o.val) }
 
-	val []byte
+func (o *oI64) equals(x obj) (bool, error) {
+	xi, ok := x.(*oI64)
+	return ok && o.val == xi.val, nil
+}
+
+func (o *oI64) binaryop(op binaryOp, x obj) (obj, error) {
+	switch xo := x.(type) {
+	case *oI64:
+		return computeI64(o.val, xo.val, op)
+
+	case *oBigInt:
+		return computeBigInt(big.NewInt(o.val), xo.val, op)
+
+	case *oF64:
+		return computeF64(float64(o.val), xo.val, op), nil
+
+	case *oStr:
+		if op == boMul {
+			return xo.repeat(int(o.val))
+		}
+
+	case *oList:
+		if op == boMul {
+			return xo.repeat(int(o.val))
+		}
+	}
+
+	return nil, nil
+}
+
+// computeI64 computes "l op r" between i64 values.
+// When the result overflows i64, it is computed again as bigint.
+func computeI64(l, r int64, op binaryOp) (obj, error) {
+	switch op {
+	case boAdd:
+		v := l + r
+		if (l^v)&(r^v) < 0 {
+			return computeBigInt(big.NewInt(l), big.NewInt(r), op)
+		}
+		return &oI64{val: v}, nil
+	case boSub:
+		v := l - r
+		if (l^r)&(l^v) < 0 {
+			return computeBigInt(big.NewInt(l), big.NewInt(r), op)
+		}
+		return &oI64{val: v}, nil
+	case boMul:
+		if l == 0 || r == 0 {
+			return &oI64{val: 0}, nil
+		}
+		v := l * r
+		if v/r != l || (l == -1 && r == math.MinInt64) || (r == -1 && l == math.MinInt64) {
+			return computeBigInt(big.NewInt(l), big.NewInt(r), op)
+		}
+		return &oI64{val: v}, nil
+	case boDiv:
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if l == math.MinInt64 && r == -1 {
+			return computeBigInt(big.NewInt(l), big.NewInt(r), op)
+		}
+		return &oI64{val: l / r}, nil
+	case boMod:
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return &oI64{val: l % r}, nil
+	case boLess:
+		return newbool(l < r), nil
+	case boLessEq:
+		return newbool(l <= r), nil
+	case boGreater:
+		return newbool(l > r), nil
+	case boGreaterEq:
+		return newbool(l >= r), nil
+	case boBitwiseOr:
+		return &oI64{val: l | r}, nil
+	case boBitwiseXor:
+		return &oI64{val: l ^ r}, nil
+	case boBitwiseAnd:
+		return &oI64{val: l & r}, nil
+	case boLeftShift:
+		if r < 0 {
+			return nil, fmt.Errorf("negative shift count %d", r)
+		}
+		if r >= 63 || (l<<r)>>r != l {
+			return computeBigInt(big.NewInt(l), big.NewInt(r), op)
+		}
+		return &oI64{val: l << r}, nil
+	case boRightShift:
+		if r < 0 {
+			return nil, fmt.Errorf("negative shift count %d", r)
+		}
+		return &oI64{val: l >> r}, nil
+	}
+
+	return nil, nil
+}
+
+func (o *oI64) unaryop(op unaryOp) obj {
+	switch op {
+	case uoPlus:
+		return o
+	case uoMinus:
+		if o.val == math.MinInt64 {
+			return newint(new(big.Int).Neg(big.NewInt(o.val)))
+		}
+		return &oI64{val: -o.val}
+	case uoBitwiseNot:
+		return &oI64{val: ^o.val}
+	}
+
+	return nil
 }
 
 /*
- * List
+ * bigint
  */
 
-type oList struct {
-	vals []*obj
+// oBigInt is an arbitrary-precision integer.
+// An i64 operation is promoted to bigint on overflow. oBigInt always holds a value which does not fit in i64,
+// because the result is converted back to i64 when it fits (see newint()).
+type oBigInt struct {
+	nonIterable
+	nonSequencable
+
+	val *big.Int
 }
 
-func (o *oList) key() objkey {
-	return fmt.Sprintf("list_%s", o)
+// newint returns i64 if v fits in i64, otherwise returns bigint.
+func newint(v *big.Int) obj {
+	if v.IsInt64() {
+		return &oI64{val: v.Int64()}
+	}
+
+	return &oBigInt{val: v}
 }
 
-func (o *oList) clone() *obj {
-	o2 := &oList{}
-	for _, oo := range o.vals {
-		o2.vals = append(o2.vals, oo.clone())
+func (o *oBigInt) typename() string { return "bigint" }
+func (o *oBigInt) clone() obj       { return o }
+func (o *oBigInt) isTruethy() bool  { return o.val.Sign() != 0 }
+func (o *oBigInt) String() string   { return o.val.String() }
+
+func (o *oBigInt) hash() (uint64, error) {
+	return hashbytes("bigint", append([]byte{byte(o.val.Sign() + 1)}, o.val.Bytes()...)), nil
+}
+
+func (o *oBigInt) equals(x obj) (bool, error) {
+	xb, ok := x.(*oBigInt)
+	return ok && o.val.Cmp(xb.val) == 0, nil
+}
+
+func (o *oBigInt) binaryop(op binaryOp, x obj) (obj, error) {
+	switch xo := x.(type) {
+	case *oBigInt:
+		return computeBigInt(o.val, xo.val, op)
+	case *oI64:
+		return computeBigInt(o.val, big.NewInt(xo.val), op)
+	case *oF64:
+		return computeF64(bigtof64(o.val), xo.val, op), nil
 	}
-	return &o2
+
+	return nil, nil
 }
 
-func (o *oList) isTruethy() bool {
-	return len(o.vals) != 0
+// computeBigInt computes "l op r" between arbitrary-precision integers.
+// Division and modulo truncate toward zero as i64 does.
+func computeBigInt(l, r *big.Int, op binaryOp) (obj, error) {
+	switch op {
+	case boAdd:
+		return newint(new(big.Int).Add(l, r)), nil
+	case boSub:
+		return newint(new(big.Int).Sub(l, r)), nil
+	case boMul:
+		return newint(new(big.Int).Mul(l, r)), nil
+	case boDiv:
+		if r.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return newint(new(big.Int).Quo(l, r)), nil
+	case boMod:
+		if r.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return newint(new(big.Int).Rem(l, r)), nil
+	case boLess:
+		return newbool(l.Cmp(r) < 0), nil
+	case boLessEq:
+		return newbool(l.Cmp(r) <= 0), nil
+	case boGreater:
+		return newbool(l.Cmp(r) > 0), nil
+	case boGreaterEq:
+		return newbool(l.Cmp(r) >= 0), nil
+	case boBitwiseOr:
+		return newint(new(big.Int).Or(l, r)), nil
+	case boBitwiseXor:
+		return newint(new(big.Int).Xor(l, r)), nil
+	case boBitwiseAnd:
+		return newint(new(big.Int).And(l, r)), nil
+	case boLeftShift, boRightShift:
+		if r.Sign() < 0 {
+			return nil, fmt.Errorf("negative shift count %s", r)
+		}
+		if !r.IsUint64() || r.Uint64() > math.MaxUint32 {
+			return nil, fmt.Errorf("shift count %s is too large", r)
+		}
+		if op == boLeftShift {
+			return newint(new(big.Int).Lsh(l, uint(r.Uint64()))), nil
+		}
+		return newint(new(big.Int).Rsh(l, uint(r.Uint64()))), nil
+	}
+
+	return nil, nil
 }
 
-func (o *oList) equals(x *obj) bool {
-	xo, ok := *x.(*oList)
-	if !ok {
-		return false
+func (o *oBigInt) unaryop(op unaryOp) obj {
+	switch op {
+	case uoPlus:
+		return o
+	case uoMinus:
+		return newint(new(big.Int).Neg(o.val))
+	case uoBitwiseNot:
+		return newint(new(big.Int).Not(o.val))
 	}
 
-	if len(o.vals) != len(xo.vals) {
-		return false
+	return nil
+}
+
+// f64tostr returns the shortest string which is read back to exactly the same f64.
+// Like Python, it always has a decimal point or exponent so that it can't be confused with i64.
+func f64tostr(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
 	}
 
-	for i := range o.vals {
-		if !o.vals[i].equals(xo.vals[i]) {
-			return false
-		}
+	abs := math.Abs(f)
+	if abs != 0 && (abs < 1e-4 || abs >= 1e16) {
+		return strconv.FormatFloat(f, 'e', -1, 64)
 	}
 
-	return true
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
 }
 
-func (o *oList) isIterable() bool {
-	return true
+func bigtof64(v *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(v).Float64()
+	return f
+}
+
+/*
+ * f64
+ */
+
+type oF64 struct {
+	nonIterable
+	nonSequencable
+
+	val float64
 }
 
-func (o *oList) iterator() iterator {
-	return &listIterator{vals: o.vals, i: 0}
+func (o *oF64) typename() string { return "f64" }
+func (o *oF64) clone() obj       { return o }
+func (o *oF64) isTruethy() bool  { return o.val != 0 }
+func (o *oF64) String() string   { return f64tostr(o.val) }
+
+func (o *oF64) hash() (uint64, error) {
+	// 0.0 and -0.0 are equal
+	if o.val == 0 {
+		return hashuint("f64", 0), nil
+	}
+	return hashuint("f64", math.Float64bits(o.val)), nil
 }
 
-func (o *oList) isSequencable() bool {
-	return true
+func (o *oF64) equals(x obj) (bool, error) {
+	xf, ok := x.(*oF64)
+	return ok && o.val == xf.val, nil
 }
 
-func (o *oList) sequence() sequence {
-	return &listSequence{vals: o.vals}
+func (o *oF64) binaryop(op binaryOp, x obj) (obj, error) {
+	switch xo := x.(type) {
+	case *oF64:
+		return computeF64(o.val, xo.val, op), nil
+	case *oI64:
+		return computeF64(o.val, float64(xo.val), op), nil
+	case *oBigInt:
+		return computeF64(o.val, bigtof64(xo.val), op), nil
+	}
+
+	return nil, nil
+}
+
+func computeF64(l, r float64, op binaryOp) obj {
+	switch op {
+	case boAdd:
+		return &oF64{val: l + r}
+	case boSub:
+		return &oF64{val: l - r}
+	case boMul:
+		return &oF64{val: l * r}
+	case boDiv:
+		return &oF64{val: l / r}
+	case boLess:
+		return newbool(l < r)
+	case boLessEq:
+		return newbool(l <= r)
+	case boGreater:
+		return newbool(l > r)
+	case boGreaterEq:
+		return newbool(l >= r)
+	}
+
+	return nil
+}
+
+func (o *oF64) unaryop(op unaryOp) obj {
+	switch op {
+	case uoPlus:
+		return o
+	case uoMinus:
+		return &oF64{val: -o.val}
+	}
+
+	return nil
 }
 
 /*
- * dict
+ * str
  */
 
-type oDict struct {
-	dict *dict
+type oStr struct {
+	nonUnaryOperable
+
+	val []byte
 }
 
-type oMod struct {
-	mod *module
+func newstr(s string) *oStr {
+	return &oStr{val: []byte(s)}
 }
 
-type oStruct struct {
-	name string
-	fields map[string]*obj
+func (o *oStr) typename() string      { return "str" }
+func (o *oStr) hash() (uint64, error) { return hashbytes("str", o.val), nil }
+func (o *oStr) clone() obj            { return o }
+func (o *oStr) isTruethy() bool       { return len(o.val) != 0 }
+func (o *oStr) String() string        { return string(o.val) }
+func (o *oStr) isIterable() bool      { return true }
+func (o *oStr) iterator() (iterator, error) {
+	return &strIterator{runes: []rune(string(o.val)), i: 0}, nil
 }
+func (o *oStr) isSequencable() bool { return true }
+func (o *oStr) sequence() sequence  { return &strSequence{runes: []rune(string(o.val))} }
 
-type oBuiltinFunc struct {
-	name string
-	body func(objs ...*obj) (*obj, error)
+func (o *oStr) equals(x obj) (bool, error) {
+	xs, ok := x.(*oStr)
+	return ok && string(o.val) == string(xs.val), nil
 }
 
-type oGoStdModFunc struct {
-	name string
-	body func(objs ...*obj) (*obj, error)
+func (o *oStr) binaryop(op binaryOp, x obj) (obj, error) {
+	switch xo := x.(type) {
+	case *oStr:
+		switch op {
+		case boAdd:
+			b := make([]byte, 0, len(o.val)+len(xo.val))
+			b = append(b, o.val...)
+			b = append(b, xo.val...)
+			return &oStr{val: b}, nil
+		case boLess:
+			return newbool(bytes.Compare(o.val, xo.val) < 0), nil
+		case boLessEq:
+			return newbool(bytes.Compare(o.val, xo.val) <= 0), nil
+		case boGreater:
+			return newbool(bytes.Compare(o.val, xo.val) > 0), nil
+		case boGreaterEq:
+			return newbool(bytes.Compare(o.val, xo.val) >= 0), nil
+		}
+
+	case *oI64:
+		if op == boMul {
+			return o.repeat(int(xo.val))
+		}
+	}
+
+	return nil, nil
 }
 
-type oFunc struct {
-	name string
-	mod   *module
-	params []string
-	body   []node
+func (o *oStr) repeat(n int) (*oStr, error) {
+	l, err := repeatlen(len(o.val), n)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, l)
+	for i := 0; i < n; i++ {
+		b = append(b, o.val...)
+	}
+	return &oStr{val: b}, nil
 }
 
-type oMethod struct {
-	name string
-	mod   *module
-	params []string
-	body   []node
-	receiver *obj
-}
-
-// type obj struct {
-// 	typ objtyp
-// 
-// 	bval  bool
-// 	fval  float64
-// 	ival  int64
-// 	bytes []byte
-// 	list  []*obj
-// 	dict  *dict
-// 	mod   *module
-// 
-// 	// builtin/func/gostdmodfunc/struct
-// 	name string
-// 
-// 	// builtin
-// 	bfnbody func(objs ...*obj) (*obj, error)
-// 
-// 	// std module implemented in Go
-// 	gostdmodfunc func(objs ...*obj) (*obj, error)
-// 
-// 	// func/method
-// 	fmod   *module
-// 	params []string
-// 	body   []node
-// 
-// 	// method
-// 	receiver *obj
-// 
-// 	// struct
-// 	fields map[string]*obj
-// }
-
-func (o *obj) update(x *obj) {
-	o.typ = x.typ
-	switch x.typ {
-	case tBool:
-		o.bval = x.bval
-	case tF64:
-		o.fval = x.fval
-	case tI64:
-		o.ival = x.ival
-	case tStr:
-		o.bytes = x.bytes
-	case tList:
-		o.list = x.list
-	case tDict:
-		o.dict = x.dict
-	case tStruct:
-		o.name = x.name
-		o.fields = x.fields
-	case tBuiltinFunc:
-		o.name = x.name
-		o.bfnbody = x.bfnbody
-	case tGoStdModFunc:
-		o.name = x.name
-		o.gostdmodfunc = x.gostdmodfunc
-	case tFunc:
-		o.name = x.name
-		o.fmod = x.fmod
-		o.params = x.params
-		o.body = x.body
-	case tMod:
-		o.mod = x.mod
-	default:
-		panic("shiba error: unhandled type in obj.update()")
-	}
-}
-
-func (o *obj) clone() *obj {
-	cloned := &obj{typ: o.typ}
-	switch o.typ {
-	case tBool:
-		cloned.bval = o.bval
-	case tF64:
-		cloned.fval = o.fval
-	case tI64:
-		cloned.ival = o.ival
-	case tStr:
-		cloned.bytes = o.bytes
-	case tList:
-		for _, oo := range o.list {
-			cloned.list = append(cloned.list, oo.clone())
-		}
-	case tDict:
-		cloned.dict = o.dict.clone()
-	case tStruct:
-		cloned.name = o.name
-		for k, v := range o.fields {
-			cloned.fields[k] = v.clone()
-		}
-	case tBuiltinFunc:
-		cloned.name = o.name
-		cloned.bfnbody = o.bfnbody
-	case tGoStdModFunc:
-		cloned.gostdmodfunc = o.gostdmodfunc
-	case tFunc:
-		cloned.name = o.name
-		cloned.fmod = o.fmod
-		cloned.params = o.params
-		cloned.body = o.body
-	case tMethod:
-		cloned.name = o.name
-		cloned.fmod = o.fmod
-		cloned.params = o.params
-		cloned.body = o.body
-		cloned.receiver = o.receiver
-	case tMod:
-		cloned.mod = o.mod
-	default:
-		panic("shiba error: unhandled type in obj.clone()")
+/*
+ * list
+ */
+
+type oList struct {
+	nonUnaryOperable
+
+	vals []obj
+}
+
+func (o *oList) typename() string            { return "list" }
+func (o *oList) hash() (uint64, error)       { return 0, errunhashable(o) }
+func (o *oList) isTruethy() bool             { return len(o.vals) != 0 }
+func (o *oList) isIterable() bool            { return true }
+func (o *oList) iterator() (iterator, error) { return &listIterator{vals: o.vals, i: 0}, nil }
+func (o *oList) isSequencable() bool         { return true }
+func (o *oList) sequence() sequence          { return &listSequence{l: o} }
+
+func (o *oList) clone() obj {
+	o2 := &oList{}
+	for _, oo := range o.vals {
+		o2.vals = append(o2.vals, oo.clone())
 	}
-	return cloned
+	return o2
 }
 
-func (o *obj) isTruthy() bool {
-	switch o.typ {
-	case tNil:
-		return false
-	case tBool:
-		return o.bval
-	case tF64:
-		return o.fval != 0
-	case tI64:
-		return o.ival != 0
-	case tStr:
-		return len(o.bytes) != 0
-	case tList:
-		return len(o.list) != 0
-	case tDict:
-		return o.dict.size() != 0
-	default:
-		return true
-	}
-}
-
-func (o *obj) equals(x *obj) bool {
-	if o.typ != x.typ {
-		return false
+func (o *oList) equals(x obj) (bool, error) {
+	xo, ok := x.(*oList)
+	if !ok {
+		return false, nil
 	}
 
-	switch o.typ {
-	case tNil:
-		return true // check only type
-	case tBool:
-		return o.bval == x.bval
-	case tF64:
-		return o.fval == x.fval
-	case tI64:
-		return o.ival == x.ival
-	case tStr:
-		if len(o.bytes) != len(x.bytes) {
-			return false
-		}
+	return valsequal(o.vals, xo.vals)
+}
 
-		for i := range o.bytes {
-			if o.bytes[i] != x.bytes[i] {
-				return false
-			}
-		}
+// valsequal reports if the both have the equal elements in the same order.
+func valsequal(a, b []obj) (bool, error) {
+	if len(a) != len(b) {
+		return false, nil
+	}
 
-		return true
-	case tList:
-		if len(o.list) != len(x.list) {
-			return false
+	for i := range a {
+		eq, err := a[i].equals(b[i])
+		if err != nil || !eq {
+			return false, err
 		}
+	}
 
-		for i := range o.list {
-			if !o.list[i].equals(x.list[i]) {
-				return false
-			}
+	return true, nil
+}
+
+func (o *oList) String() string {
+	sb := strings.Builder{}
+	sb.WriteString("[")
+	for i, val := range o.vals {
+		sb.WriteString(val.String())
+		if i < len(o.vals)-1 {
+			sb.WriteString(", ")
 		}
+	}
+	sb.WriteString("]")
 
-		return true
+	return sb.String()
+}
 
-	case tDict:
-		return o.dict.equals(x.dict)
-	case tMod:
-		return o.mod == x.mod
-	case tBuiltinFunc:
-		return o.name == x.name
-	case tGoStdModFunc:
-		return o.name == x.name
-	case tStruct:
-		if o.name != x.name {
-			return false
+func (o *oList) binaryop(op binaryOp, x obj) (obj, error) {
+	switch xo := x.(type) {
+	case *oList:
+		if op == boAdd {
+			vals := make([]obj, 0, len(o.vals)+len(xo.vals))
+			vals = append(vals, o.vals...)
+			vals = append(vals, xo.vals...)
+			return &oList{vals: vals}, nil
 		}
 
-		for k, v := range o.fields {
-			v2, ok := x.fields[k]
-			if !ok {
-				return false
-			}
-			if !v.equals(v2) {
-				return false
-			}
+	case *oI64:
+		if op == boMul {
+			return o.repeat(int(xo.val))
 		}
+	}
 
-		return true
-
-	default:
-		return o.fmod == x.fmod && o.name == x.name
-	}
-}
-
-func (o *obj) String() string {
-	switch o.typ {
-	case tNil:
-		return "<nil>"
-	case tBool:
-		return fmt.Sprintf("%t", o.bval)
-	case tF64:
-		return fmt.Sprintf("%f", o.fval)
-	case tI64:
-		return fmt.Sprintf("%d", o.ival)
-	case tStr:
-		return string(o.bytes)
-	case tList:
-		sb := strings.Builder{}
-		sb.WriteString("[")
-		for i, val := range o.list {
-			sb.WriteString(val.String())
-			if i < len(o.list)-1 {
-				sb.WriteString(", ")
-			}
-		}
-		sb.WriteString("]")
-
-		return sb.String()
-	case tDict:
-		return o.dict.String()
-	case tStruct:
-		sb := strings.Builder{}
-		sb.WriteString(o.name)
-		sb.WriteString("{")
-		i := 0
-		for k, v := range o.fields {
-			if v.typ == tMethod {
-				i++
-				continue
-			}
-			sb.WriteString(k + ":" + v.String())
-			if i < len(o.fields)-1 {
-				sb.WriteString(", ")
-			}
-			i++
-		}
-		sb.WriteString("}")
+	return nil, nil
+}
+
+// repeat returns a new list which repeats o n times.
+// list * (0 | neg) returns empty list.
+func (o *oList) repeat(n int) (*oList, error) {
+	l, err := repeatlen(len(o.vals), n)
+	if err != nil {
+		return nil, err
+	}
 
-		return sb.String()
-	case tMod:
-		return o.mod.name
-	case tBuiltinFunc:
-		return o.name
-	case tGoStdModFunc:
-		return o.name
-	case tFunc:
-		return o.fmod.name + "/" + o.name
+	ret := &oList{vals: make([]obj, 0, l)}
+	for i := 0; i < n; i++ {
+		ret.vals = append(ret.vals, o.vals...)
 	}
-	return "?"
+	return ret, nil
 }
 
-func (o *obj) isiterable() bool {
-	return o.typ == tStr || o.typ == tList || o.typ == tDict
+/*
+ * tuple
+ */
+
+// oTuple is an immutable list. It can be a dict key if all the elements can be.
+type oTuple struct {
+	nonUnaryOperable
+
+	vals []obj
 }
 
-func (o *obj) iterator() iterator {
-	switch o.typ {
-	case tStr:
-		return &strIterator{runes: []rune(string(o.bytes)), i: 0}
-	case tList:
-		return &listIterator{vals: o.list, i: 0}
-	default:
-		return &dictIterator{d: o.dict, i: 0, e: o.dict.keys.Front()}
+func (o *oTuple) typename() string            { return "tuple" }
+func (o *oTuple) isTruethy() bool             { return len(o.vals) != 0 }
+func (o *oTuple) isIterable() bool            { return true }
+func (o *oTuple) iterator() (iterator, error) { return &listIterator{vals: o.vals, i: 0}, nil }
+func (o *oTuple) isSequencable() bool         { return true }
+func (o *oTuple) sequence() sequence          { return &tupleSequence{vals: o.vals} }
+
+// hash is computed from the hashes of the elements so that the equal tuples have the same hash.
+func (o *oTuple) hash() (uint64, error) {
+	h := hashuint("tuple", uint64(len(o.vals)))
+	for _, val := range o.vals {
+		vh, err := val.hash()
+		if err != nil {
+			return 0, err
+		}
+		h = h*31 + vh
 	}
+	return h, nil
 }
 
-func (o *obj) cansequence() bool {
-	return o.typ == tStr || o.typ == tList
+func (o *oTuple) clone() obj {
+	o2 := &oTuple{vals: make([]obj, len(o.vals))}
+	for i, oo := range o.vals {
+		o2.vals[i] = oo.clone()
+	}
+	return o2
 }
 
-func (o *obj) sequence() sequence {
-	switch o.typ {
-	case tStr:
-		return &strSequence{runes: []rune(string(o.bytes))}
-	default:
-		return &listSequence{vals: o.list}
+func (o *oTuple) equals(x obj) (bool, error) {
+	xt, ok := x.(*oTuple)
+	if !ok {
+		return false, nil
 	}
+
+	return valsequal(o.vals, xt.vals)
 }
 
-func computeBinaryOp(l, r *obj, op binaryOp) (*obj, error) {
-	if op == boEq {
-		return &obj{typ: tBool, bval: l.equals(r)}, nil
+// String returns "(a, b)". The tuple with single element is "(a,)" to be distinguished from the value itself.
+func (o *oTuple) String() string {
+	ss := make([]string, len(o.vals))
+	for i, val := range o.vals {
+		ss[i] = val.String()
 	}
 
-	if op == boNotEq {
-		return &obj{typ: tBool, bval: !l.equals(r)}, nil
+	if len(ss) == 1 {
+		return "(" + ss[0] + ",)"
 	}
+	return "(" + strings.Join(ss, ", ") + ")"
+}
 
-	lt := l.typ
-	rt := r.typ
-
-	switch lt {
-	case tStr:
-		lb := l.bytes
-		switch op {
-		case boAdd:
-			if rt == tStr {
-				return &obj{typ: tStr, bytes: append(lb, r.bytes...)}, nil
-			}
+func (o *oTuple) binaryop(op binaryOp, x obj) (obj, error) {
+	switch xo := x.(type) {
+	case *oTuple:
+		if op == boAdd {
+			vals := make([]obj, 0, len(o.vals)+len(xo.vals))
+			vals = append(vals, o.vals...)
+			vals = append(vals, xo.vals...)
+			return &oTuple{vals: vals}, nil
+		}
 
-		case boMul:
-			if rt == tI64 {
-				b := []byte{}
-				for i := 0; i < int(r.ival); i++ {
-					b = append(b, lb...)
-				}
-				return &obj{typ: tStr, bytes: b}, nil
+	case *oI64:
+		if op == boMul {
+			l, err := (&oList{vals: o.vals}).repeat(int(xo.val))
+			if err != nil {
+				return nil, err
 			}
+			return &oTuple{vals: l.vals}, nil
 		}
-	case tList:
-		ll := l.list
-		switch op {
-		case boAdd:
-			if rt == tList {
-				return &obj{typ: tList, list: append(ll, r.list...)}, nil
-			}
+	}
 
-		case boMul:
-			if rt == tI64 {
-				ri := int(r.ival)
-				if ri <= 0 {
-					// list * (0 | neg) returns empty list
-					return &obj{typ: tList}, nil
-				}
+	return nil, nil
+}
 
-				ret := &obj{typ: tList}
-				for i := 0; i < ri; i++ {
-					ret.list = append(ret.list, ll...)
-				}
+/*
+ * dict
+ */
 
-				return ret, nil
-			}
-		}
-	case tI64:
-		li := l.ival
-		lf := float64(li)
-		switch op {
-		case boAdd:
-			if rt == tI64 {
-				return &obj{typ: tI64, ival: li + r.ival}, nil
-			}
-			if rt == tF64 {
-				return &obj{typ: tF64, fval: lf + r.fval}, nil
-			}
-		case boSub:
-			if rt == tI64 {
-				return &obj{typ: tI64, ival: li - r.ival}, nil
-			}
-			if rt == tF64 {
-				return &obj{typ: tF64, fval: lf - r.fval}, nil
-			}
-		case boMul:
-			if rt == tStr {
-				b := []byte{}
-				for i := 0; i < int(li); i++ {
-					b = append(b, r.bytes...)
-				}
-				return &obj{typ: tStr, bytes: b}, nil
-			}
-			if rt == tList {
-				lii := int(li)
-				if lii <= 0 {
-					// list * (0 | neg) returns empty list
-					return &obj{typ: tList}, nil
-				}
-
-				ret := &obj{typ: tList}
-				for i := 0; i < lii; i++ {
-					ret.list = append(ret.list, r.list...)
-				}
-
-				return ret, nil
-			}
-			if rt == tI64 {
-				return &obj{typ: tI64, ival: li * r.ival}, nil
-			}
-			if rt == tF64 {
-				return &obj{typ: tF64, fval: lf * r.fval}, nil
-			}
-		case boDiv:
-			if rt == tI64 {
-				return &obj{typ: tI64, ival: li / r.ival}, nil
-			}
-			if rt == tF64 {
-				return &obj{typ: tF64, fval: lf / r.fval}, nil
-			}
-		case boMod:
-			if rt == tI64 {
-				return &obj{typ: tI64, ival: li % r.ival}, nil
-			}
-		case boLess:
-			if rt == tI64 {
-				return &obj{typ: tBool, bval: li < r.ival}, nil
-			}
-			if rt == tF64 {
-				return &obj{typ: tBool, bval: lf < r.fval}, nil
-			}
-		case boLessEq:
-			if rt == tI64 {
-				return &obj{typ: tBool, bval: li <= r.ival}, nil
-			}
-			if rt == tF64 {
-				return &obj{typ: tBool, bval: lf <= r.fval}, nil
-			}
-		case boGreater:
-			if rt == tI64 {
-				return &obj{typ: tBool, bval: li > r.ival}, nil
-			}
-			if rt == tF64 {
-				return &obj{typ: tBool, bval: lf > r.fval}, nil
-			}
-		case boGreaterEq:
-			if rt == tI64 {
-				return &obj{typ: tBool, bval: li >= r.ival}, nil
-			}
-			if rt == tF64 {
-				return &obj{typ: tBool, bval: lf >= r.fval}, nil
-			}
-		case boBitwiseOr:
-			if rt == tI64 {
-				return &obj{typ: tI64, ival: li | r.ival}, nil
-			}
-		case boBitwiseXor:
-			if rt == tI64 {
-				return &obj{typ: tI64, ival: li ^ r.ival}, nil
-			}
-		case boBitwiseAnd:
-			if rt == tI64 {
-				return &obj{typ: tI64, ival: li & r.ival}, nil
-			}
-		case boLeftShift:
-			if rt == tI64 {
-				return &obj{typ: tI64, ival: li << r.ival}, nil
-			}
-		case boRightShift:
-			if rt == tI64 {
-				return &obj{typ: tI64, ival: li >> r.ival}, nil
-			}
+type oDict struct {
+	nonSequencable
+	nonUnaryOperable
+
+	dict *dict
+}
+
+func (o *oDict) typename() string      { return "dict" }
+func (o *oDict) hash() (uint64, error) { return 0, errunhashable(o) }
+func (o *oDict) clone() obj            { return &oDict{dict: o.dict.clone()} }
+func (o *oDict) isTruethy() bool       { return o.dict.size() != 0 }
+func (o *oDict) String() string        { return o.dict.String() }
+func (o *oDict) isIterable() bool      { return true }
+func (o *oDict) iterator() (iterator, error) {
+	return &dictIterator{d: o.dict, i: 0, e: o.dict.entries.Front()}, nil
+}
+
+func (o *oDict) equals(x obj) (bool, error) {
+	xd, ok := x.(*oDict)
+	if !ok {
+		return false, nil
+	}
+
+	return o.dict.equals(xd.dict)
+}
+
+func (o *oDict) binaryop(op binaryOp, x obj) (obj, error) {
+	xd, ok := x.(*oDict)
+	if !ok || op != boBitwiseOr {
+		return nil, nil
+	}
+
+	// merge. the value in the right side wins.
+	d := o.dict.copy()
+	if err := d.update(xd.dict); err != nil {
+		return nil, err
+	}
+
+	return &oDict{dict: d}, nil
+}
+
+/*
+ * set
+ */
+
+// oSet is a set built on dict. The elements are the keys of the dict, so the order is the insertion order.
+type oSet struct {
+	nonSequencable
+	nonUnaryOperable
+
+	dict *dict
+}
+
+func newset(vals []obj) (*oSet, error) {
+	s := &oSet{dict: newdict()}
+	for _, v := range vals {
+		if err := s.add(v); err != nil {
+			return nil, err
 		}
-	case tF64:
-		lf := l.fval
-		switch op {
-		case boAdd:
-			if rt == tF64 {
-				return &obj{typ: tF64, fval: lf + r.fval}, nil
-			}
-			if rt == tI64 {
-				return &obj{typ: tF64, fval: lf + float64(r.ival)}, nil
-			}
-		case boSub:
-			if rt == tF64 {
-				return &obj{typ: tF64, fval: lf - r.fval}, nil
-			}
-			if rt == tI64 {
-				return &obj{typ: tF64, fval: lf - float64(r.ival)}, nil
-			}
-		case boMul:
-			if rt == tF64 {
-				return &obj{typ: tF64, fval: lf * r.fval}, nil
-			}
-			if rt == tI64 {
-				return &obj{typ: tF64, fval: lf * float64(r.ival)}, nil
-			}
-		case boDiv:
-			if rt == tF64 {
-				return &obj{typ: tF64, fval: lf / r.fval}, nil
-			}
-			if rt == tI64 {
-				return &obj{typ: tF64, fval: lf / float64(r.ival)}, nil
-			}
-		case boLess:
-			if rt == tF64 {
-				return &obj{typ: tBool, bval: lf < r.fval}, nil
-			}
-			if rt == tI64 {
-				return &obj{typ: tBool, bval: lf < float64(r.ival)}, nil
-			}
-		case boLessEq:
-			if rt == tF64 {
-				return &obj{typ: tBool, bval: lf <= r.fval}, nil
-			}
-			if rt == tI64 {
-				return &obj{typ: tBool, bval: lf <= float64(r.ival)}, nil
-			}
-		case boGreater:
-			if rt == tF64 {
-				return &obj{typ: tBool, bval: lf > r.fval}, nil
-			}
-			if rt == tI64 {
-				return &obj{typ: tBool, bval: lf > float64(r.ival)}, nil
-			}
-		case boGreaterEq:
-			if rt == tF64 {
-				return &obj{typ: tBool, bval: lf >= r.fval}, nil
-			}
-			if rt == tI64 {
-				return &obj{typ: tBool, bval: lf >= float64(r.ival)}, nil
-			}
+	}
+	return s, nil
+}
+
+func (o *oSet) typename() string { return "set" }
+func (o *oSet) clone() obj       { return &oSet{dict: o.dict.clone()} }
+func (o *oSet) isTruethy() bool  { return o.dict.size() != 0 }
+func (o *oSet) isIterable() bool { return true }
+func (o *oSet) iterator() (iterator, error) {
+	return &dictIterator{d: o.dict, i: 0, e: o.dict.entries.Front()}, nil
+}
+
+func (o *oSet) hash() (uint64, error) { return 0, errunhashable(o) }
+
+// add adds v to the set. It fails if v is not hashable.
+func (o *oSet) add(v obj) error {
+	return o.dict.set(v, NIL)
+}
+
+func (o *oSet) has(v obj) (bool, error) {
+	_, ok, err := o.dict.get(v)
+	return ok, err
+}
+
+// filter returns the new set which has the elements whose existence in x is the same as in.
+// The hashes of the elements are reused.
+func (o *oSet) filter(x *oSet, in bool) (*oSet, error) {
+	s := &oSet{dict: newdict()}
+	for e := o.dict.entries.Front(); e != nil; e = e.Next() {
+		de := e.Value.(*dictentry)
+		found, err := x.dict.lookup(de.hash, de.k)
+		if err != nil {
+			return nil, err
 		}
-	case tBool:
-		lb := l.bval
-		switch op {
-		case boLogicalOr:
-			if rt == tBool {
-				return &obj{typ: tBool, bval: lb || r.bval}, nil
-			}
-		case boLogicalAnd:
-			if rt == tBool {
-				return &obj{typ: tBool, bval: lb && r.bval}, nil
-			}
+
+		if (found != nil) == in {
+			s.dict.push(&dictentry{hash: de.hash, k: de.k, v: NIL})
+		}
+	}
+	return s, nil
+}
+
+// equals reports if the both sets have the same elements regardless of the order.
+func (o *oSet) equals(x obj) (bool, error) {
+	xs, ok := x.(*oSet)
+	if !ok || o.dict.size() != xs.dict.size() {
+		return false, nil
+	}
+
+	common, err := o.filter(xs, true)
+	if err != nil {
+		return false, err
+	}
+
+	return common.dict.size() == o.dict.size(), nil
+}
+
+// String returns "{a, b}". The empty set is "set()" as "{}" is the empty dict.
+func (o *oSet) String() string {
+	if o.dict.size() == 0 {
+		return "set()"
+	}
+
+	keys := o.dict.keys()
+	ss := make([]string, len(keys))
+	for i, k := range keys {
+		ss[i] = k.String()
+	}
+	return "{" + strings.Join(ss, ", ") + "}"
+}
+
+// binaryop computes union (|), intersection (&), difference (-) and symmetric difference (^).
+// The result keeps the order of the left set, then the right.
+func (o *oSet) binaryop(op binaryOp, x obj) (obj, error) {
+	xs, ok := x.(*oSet)
+	if !ok {
+		return nil, nil
+	}
+
+	switch op {
+	case boBitwiseOr:
+		s := &oSet{dict: o.dict.copy()}
+		if err := s.dict.update(xs.dict); err != nil {
+			return nil, err
+		}
+		return s, nil
+
+	case boBitwiseAnd:
+		return o.filter(xs, true)
+
+	case boSub:
+		return o.filter(xs, false)
+
+	case boBitwiseXor:
+		s, err := o.filter(xs, false)
+		if err != nil {
+			return nil, err
+		}
+
+		rest, err := xs.filter(o, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.dict.update(rest.dict); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	return nil, nil
+}
+
+/*
+ * range
+ */
+
+// oRange is the lazy sequence of integers created by range().
+// Values are computed on iteration so that a large range does not allocate.
+type oRange struct {
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
+
+	start, stop, step int64
+}
+
+func (o *oRange) typename() string            { return "range" }
+func (o *oRange) hash() (uint64, error)       { return hashbytes("range", []byte(o.String())), nil }
+func (o *oRange) clone() obj                  { return o }
+func (o *oRange) isTruethy() bool             { return o.size() != 0 }
+func (o *oRange) isIterable() bool            { return true }
+func (o *oRange) iterator() (iterator, error) { return &rangeIterator{r: o, cur: o.start}, nil }
+
+func (o *oRange) String() string {
+	return fmt.Sprintf("range(%d, %d, %d)", o.start, o.stop, o.step)
+}
+
+func (o *oRange) equals(x obj) (bool, error) {
+	xr, ok := x.(*oRange)
+	return ok && o.start == xr.start && o.stop == xr.stop && o.step == xr.step, nil
+}
+
+// size returns the number of the values in the range.
+// range() does not create the range whose size does not fit in int.
+func (o *oRange) size() int {
+	return int(rangelen(o.start, o.stop, o.step))
+}
+
+// rangelen returns the number of the values from start to stop (exclusive) stepping by step.
+// It is computed in uint64 because the distance between two int64 can overflow int64.
+func rangelen(start, stop, step int64) uint64 {
+	if step > 0 && start < stop {
+		return (uint64(stop)-uint64(start)-1)/uint64(step) + 1
+	}
+
+	if step < 0 && start > stop {
+		return (uint64(start)-uint64(stop)-1)/uint64(-step) + 1
+	}
+
+	return 0
+}
+
+/*
+ * struct
+ */
+
+// oStruct is the instance of user-defined struct.
+// The operators, iteration and printing consult the special methods such as Add, Iter and String.
+type oStruct struct {
+	nonSequencable
+	nonUnaryOperable
+
+	def    *structdef
+	fields map[string]obj
+}
+
+func (o *oStruct) typename() string { return "struct" }
+func (o *oStruct) isTruethy() bool  { return true }
+
+func (o *oStruct) hash() (uint64, error) {
+	return o.def.hash(o)
+}
+
+func (o *oStruct) clone() obj {
+	cloned := &oStruct{def: o.def, fields: map[string]obj{}}
+	for k, v := range o.fields {
+		cloned.fields[k] = v.clone()
+	}
+	return cloned
+}
+
+func (o *oStruct) isIterable() bool {
+	_, ok := o.def.findmethod("Iter")
+	return ok
+}
+
+// iterator iterates the iterable returned by Iter method.
+func (o *oStruct) iterator() (iterator, error) {
+	it, ok, err := o.def.iter(o)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("%s is not iterable", o.def.name)
+	}
+
+	return it.iterator()
+}
+
+func (o *oStruct) binaryop(op binaryOp, x obj) (obj, error) {
+	return o.def.binaryop(o, op, x)
+}
+
+// equals calls Eq method if it is defined.
+func (o *oStruct) equals(x obj) (bool, error) {
+	return o.def.eq(o, x)
+}
+
+// fieldsequal reports if x is the same struct and has the equal fields.
+func (o *oStruct) fieldsequal(x obj) (bool, error) {
+	xs, ok := x.(*oStruct)
+	if !ok {
+		return false, nil
+	}
+
+	if o.def.name != xs.def.name || len(o.fields) != len(xs.fields) {
+		return false, nil
+	}
+
+	for k, v := range o.fields {
+		v2, ok := xs.fields[k]
+		if !ok {
+			return false, nil
+		}
+
+		eq, err := v.equals(v2)
+		if err != nil || !eq {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// embedded follows the path of the embedded field names from the struct.
+func (o *oStruct) embedded(path []string) (*oStruct, error) {
+	s := o
+	for _, name := range path {
+		e, ok := s.fields[name].(*oStruct)
+		if !ok {
+			return nil, fmt.Errorf("embedded %s in %s is not a struct", name, s.def.name)
 		}
+		s = e
 	}
 
-	return nil, fmt.Errorf("cannot compute: %s %s %s", l, op, r)
+	return s, nil
+}
+
+// lookup returns the struct which declares the field or method, that is the struct itself or the embedded one.
+// nil is returned if nothing declares it.
+func (o *oStruct) lookup(name string) (*oStruct, error) {
+	path, ok, err := o.def.resolve(name)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return o.embedded(path)
+}
+
+// member returns the field or the method bound to the struct which declares it.
+func (o *oStruct) member(name string) (obj, bool) {
+	holder, err := o.lookup(name)
+	if err != nil || holder == nil {
+		return nil, false
+	}
+
+	if f, ok := holder.fields[name]; ok {
+		return f, true
+	}
+
+	if m, ok := holder.def.getmethod(name); ok {
+		return m.bind(holder), true
+	}
+
+	return nil, false
+}
+
+// setfield sets the field, which can be promoted. It returns false if the struct does not have the field.
+func (o *oStruct) setfield(name string, v obj) bool {
+	holder, err := o.lookup(name)
+	if err != nil || holder == nil || !holder.def.hasfield(name) {
+		return false
+	}
+
+	holder.fields[name] = v
+	return true
+}
+
+// String calls String method if it is defined. The error in String is ignored and the fields are written instead.
+func (o *oStruct) String() string {
+	if str, ok, err := o.def.str(o); ok && err == nil {
+		return str
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(o.def.name)
+	sb.WriteString("{")
+	i := 0
+	// fields are written in the defined order
+	for _, k := range o.def.vars {
+		v, ok := o.fields[k]
+		if !ok {
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(k + ":" + v.String())
+		i++
+	}
+	sb.WriteString("}")
+
+	return sb.String()
+}
+
+/*
+ * module
+ */
+
+type oMod struct {
+	nonIterable
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
+
+	mod *module
+}
+
+func (o *oMod) typename() string      { return "module" }
+func (o *oMod) hash() (uint64, error) { return hashbytes("module", []byte(o.String())), nil }
+func (o *oMod) clone() obj            { return o }
+func (o *oMod) isTruethy() bool       { return true }
+func (o *oMod) String() string        { return o.mod.name }
+
+func (o *oMod) equals(x obj) (bool, error) {
+	xm, ok := x.(*oMod)
+	return ok && o.mod == xm.mod, nil
+}
+
+/*
+ * file
+ */
+
+// oFile is an open file identified by its file descriptor.
+type oFile struct {
+	nonIterable
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
+
+	fd   int
+	name string
+}
+
+func (o *oFile) typename() string      { return "file" }
+func (o *oFile) hash() (uint64, error) { return hashbytes("file", []byte(strconv.Itoa(o.fd))), nil }
+func (o *oFile) clone() obj            { return o }
+func (o *oFile) isTruethy() bool       { return true }
+func (o *oFile) String() string        { return fmt.Sprintf("<file %s>", o.name) }
+
+func (o *oFile) equals(x obj) (bool, error) {
+	xf, ok := x.(*oFile)
+	return ok && o.fd == xf.fd, nil
+}
+
+/*
+ * builtin func
+ */
+
+type oBuiltinFunc struct {
+	nonIterable
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
+
+	name string
+	body func(env *environment, kw *kwargs, objs ...obj) (obj, error)
+	// kwnames is the names of the keyword args the func accepts. Other keyword args are rejected before calling body.
+	kwnames []string
+}
+
+func (o *oBuiltinFunc) typename() string      { return "builtinfunc" }
+func (o *oBuiltinFunc) hash() (uint64, error) { return hashbytes("builtinfunc", []byte(o.name)), nil }
+func (o *oBuiltinFunc) clone() obj            { return o }
+func (o *oBuiltinFunc) isTruethy() bool       { return true }
+func (o *oBuiltinFunc) String() string        { return o.name }
+
+func (o *oBuiltinFunc) equals(x obj) (bool, error) {
+	xb, ok := x.(*oBuiltinFunc)
+	return ok && o.name == xb.name, nil
+}
+
+/*
+ * func in std module written in Go
+ */
+
+type oGoStdModFunc struct {
+	nonIterable
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
+
+	name string
+	body func(env *environment, kw *kwargs, objs ...obj) (obj, error)
+	// kwnames is the names of the keyword args the func accepts. Other keyword args are rejected before calling body.
+	kwnames []string
+}
+
+func (o *oGoStdModFunc) typename() string      { return "gostdmodfunc" }
+func (o *oGoStdModFunc) hash() (uint64, error) { return hashbytes("gostdmodfunc", []byte(o.name)), nil }
+func (o *oGoStdModFunc) clone() obj            { return o }
+func (o *oGoStdModFunc) isTruethy() bool       { return true }
+func (o *oGoStdModFunc) String() string        { return o.name }
+
+func (o *oGoStdModFunc) equals(x obj) (bool, error) {
+	xg, ok := x.(*oGoStdModFunc)
+	return ok && o.name == xg.name, nil
+}
+
+/*
+ * func
+ */
+
+type oFunc struct {
+	nonIterable
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
+
+	name   string
+	mod    *module
+	params []*param
+	body   []node
+}
+
+func (o *oFunc) typename() string      { return "func" }
+func (o *oFunc) hash() (uint64, error) { return hashbytes("func", []byte(o.String())), nil }
+func (o *oFunc) clone() obj            { return o }
+func (o *oFunc) isTruethy() bool       { return true }
+func (o *oFunc) String() string        { return o.mod.name + "/" + o.name }
+
+func (o *oFunc) equals(x obj) (bool, error) {
+	xf, ok := x.(*oFunc)
+	return ok && o.mod == xf.mod && o.name == xf.name, nil
+}
+
+/*
+ * method
+ */
+
+type oMethod struct {
+	nonIterable
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
+
+	name   string
+	mod    *module
+	params []*param
+	body   []node
+	// receiver is nil while the method is held in structdef.
+	receiver *oStruct
+	// recvname is the name of the receiver in the method body. It is "self" unless it is named.
+	recvname string
+}
+
+func (o *oMethod) typename() string      { return "method" }
+func (o *oMethod) hash() (uint64, error) { return hashbytes("method", []byte(o.String())), nil }
+func (o *oMethod) clone() obj            { return o }
+func (o *oMethod) isTruethy() bool       { return true }
+func (o *oMethod) String() string        { return o.mod.name + "/" + o.name }
+
+func (o *oMethod) equals(x obj) (bool, error) {
+	xm, ok := x.(*oMethod)
+	return ok && o.mod == xm.mod && o.name == xm.name && o.receiver == xm.receiver, nil
+}
+
+// bind returns the method bound to the receiver.
+func (o *oMethod) bind(receiver *oStruct) *oMethod {
+	return &oMethod{name: o.name, mod: o.mod, params: o.params, body: o.body, receiver: receiver, recvname: o.recvname}
+}
+
+/*
+ * type
+ */
+
+type oType struct {
+	nonIterable
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
+
+	name string
+	// def is set when the type is a user-defined struct.
+	def *structdef
+	// conv converts args into the type when the type is called like a function.
+	// nil if the type is not callable.
+	conv func(args ...obj) (obj, error)
+}
+
+func (o *oType) typename() string      { return "type" }
+func (o *oType) hash() (uint64, error) { return hashbytes("type", []byte(o.name)), nil }
+func (o *oType) clone() obj            { return o }
+func (o *oType) isTruethy() bool       { return true }
+func (o *oType) String() string        { return o.name }
+
+func (o *oType) equals(x obj) (bool, error) {
+	xt, ok := x.(*oType)
+	return ok && o.name == xt.name && o.def == xt.def, nil
+}
+
+/*
+ * interface
+ */
+
+type oInterface struct {
+	nonIterable
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
+
+	name    string
+	methods []*methodsig
+}
+
+func (o *oInterface) typename() string           { return "interface" }
+func (o *oInterface) hash() (uint64, error)      { return hashbytes("interface", []byte(o.name)), nil }
+func (o *oInterface) clone() obj                 { return o }
+func (o *oInterface) isTruethy() bool            { return true }
+func (o *oInterface) String() string             { return o.name }
+func (o *oInterface) equals(x obj) (bool, error) { return o == x, nil }
+
+/*
+ * enum
+ */
+
+// oEnum is the enum type. It is iterable over its variants.
+type oEnum struct {
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
+
+	name     string
+	variants []*oVariant
+}
+
+func (o *oEnum) typename() string           { return "enum" }
+func (o *oEnum) hash() (uint64, error)      { return hashbytes("enum", []byte(o.name)), nil }
+func (o *oEnum) clone() obj                 { return o }
+func (o *oEnum) isTruethy() bool            { return true }
+func (o *oEnum) String() string             { return o.name }
+func (o *oEnum) equals(x obj) (bool, error) { return o == x, nil }
+func (o *oEnum) isIterable() bool           { return true }
+
+// iterator iterates the variants in the defined order.
+// The variant without payload is the value itself, and the one with payload is its constructor.
+func (o *oEnum) iterator() (iterator, error) {
+	vals := make([]obj, len(o.variants))
+	for i, v := range o.variants {
+		vals[i] = v.member()
+	}
+	return &listIterator{vals: vals, i: 0}, nil
+}
+
+func (o *oEnum) variant(name string) (*oVariant, bool) {
+	for _, v := range o.variants {
+		if v.name == name {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// oVariant is the variant of the enum. If the variant has payload, it is the constructor of the value.
+type oVariant struct {
+	nonIterable
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
+
+	enum   *oEnum
+	name   string
+	params []*param
+	// unit is the only value of the variant without payload.
+	unit *oEnumValue
+}
+
+func (o *oVariant) typename() string           { return "variant" }
+func (o *oVariant) hash() (uint64, error)      { return hashbytes("variant", []byte(o.String())), nil }
+func (o *oVariant) clone() obj                 { return o }
+func (o *oVariant) isTruethy() bool            { return true }
+func (o *oVariant) String() string             { return o.enum.name + "." + o.name }
+func (o *oVariant) equals(x obj) (bool, error) { return o == x, nil }
+
+// member returns what "Enum.Variant" is evaluated to.
+func (o *oVariant) member() obj {
+	if o.unit != nil {
+		return o.unit
+	}
+	return o
+}
+
+// oEnumValue is the value of the enum. Its payload cannot be reassigned,
+// but a mutable payload such as list is copied when the value is cloned, as tuple does.
+type oEnumValue struct {
+	nonIterable
+	nonSequencable
+	nonBinaryOperable
+	nonUnaryOperable
+
+	variant *oVariant
+	vals    []obj
+}
+
+func (o *oEnumValue) typename() string { return "enum" }
+func (o *oEnumValue) isTruethy() bool  { return true }
+
+func (o *oEnumValue) clone() obj {
+	// unit variants have nothing to copy
+	if len(o.vals) == 0 {
+		return o
+	}
+
+	o2 := &oEnumValue{variant: o.variant, vals: make([]obj, len(o.vals))}
+	for i, oo := range o.vals {
+		o2.vals[i] = oo.clone()
+	}
+	return o2
+}
+
+func (o *oEnumValue) hash() (uint64, error) {
+	h := hashbytes("enum", []byte(o.variant.String()))
+	for _, val := range o.vals {
+		vh, err := val.hash()
+		if err != nil {
+			return 0, err
+		}
+		h = h*31 + vh
+	}
+	return h, nil
+}
+
+func (o *oEnumValue) equals(x obj) (bool, error) {
+	xv, ok := x.(*oEnumValue)
+	if !ok || o.variant != xv.variant {
+		return false, nil
+	}
+
+	return valsequal(o.vals, xv.vals)
+}
+
+// field returns the payload of the name.
+func (o *oEnumValue) field(name string) (obj, bool) {
+	for i, p := range o.variant.params {
+		if p.name == name {
+			return o.vals[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// String returns "Enum.Variant" or "Enum.Variant(a, b)".
+func (o *oEnumValue) String() string {
+	if len(o.vals) == 0 {
+		return o.variant.String()
+	}
+
+	vals := make([]string, len(o.vals))
+	for i, v := range o.vals {
+		vals[i] = v.String()
+	}
+	return o.variant.String() + "(" + strings.Join(vals, ", ") + ")"
 }
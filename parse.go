@@ -2,12 +2,16 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 type parser struct {
 	tokenizer *tokenizer
 	cur       *token
+	// labels of the loops which enclose the current statement
+	labels []string
 }
 
 /*
@@ -20,6 +24,18 @@ func newparser(mod *module) *parser {
 	return p
 }
 
+// newsubparser returns the parser for a code fragment in the module, such as an expression in f-string.
+// The fragment starts at l, so the tokens in it have the right location in the module.
+func newsubparser(mod *module, content []rune, l *loc) *parser {
+	m := &module{name: mod.name, filename: mod.filename, directory: mod.directory, content: content}
+	tz := newtokenizer(m)
+	tz.tr.line = l.line
+	tz.tr.col = l.col
+	p := &parser{tokenizer: tz}
+	p.proceed()
+	return p
+}
+
 func (p *parser) mark() int {
 	return p.tokenizer.mark()
 }
@@ -73,6 +89,12 @@ func (p *parser) parsestmt() (n node, err shibaErr) {
 	// Returning error will make the parser code not easy to read.
 	defer func() {
 		if r := recover(); r != nil {
+			// error from tokenizer has its own precise location
+			if e, ok := r.(shibaErr); ok {
+				err = e
+				return
+			}
+
 			err = newsberr2(p.cur.loc, "%v", r)
 		}
 	}()
@@ -104,17 +126,33 @@ func (p *parser) stmt() node {
 	}
 
 	if p.iscur(tkFor) {
-		return p._for()
+		return p._for("")
 	}
 
 	if p.iscur(tkDef) {
 		return p.def()
 	}
 
+	if p.iscur(tkTry) {
+		return p._try()
+	}
+
+	if p.iscur(tkMatch) {
+		return p.match()
+	}
+
 	if p.iscur(tkStruct) {
 		return p.structdef()
 	}
 
+	if p.iscur(tkInterface) {
+		return p.interfacedef()
+	}
+
+	if p.iscur(tkEnum) {
+		return p.enumdef()
+	}
+
 	if p.iscur(tkReturn) {
 		return p._return()
 	}
@@ -122,12 +160,21 @@ func (p *parser) stmt() node {
 	if p.iscur(tkContinue) {
 		n := &ndContinue{tok: p.cur}
 		p.proceed()
+		n.label = p.looplabel()
 		return n
 	}
 
 	if p.iscur(tkBreak) {
 		n := &ndBreak{tok: p.cur}
 		p.proceed()
+		n.label = p.looplabel()
+		return n
+	}
+
+	if p.iscur(tkDel) {
+		n := &ndDel{tok: p.cur}
+		p.proceed()
+		n.targets = p.exprlist()
 		return n
 	}
 
@@ -143,6 +190,16 @@ func (p *parser) stmt() node {
 
 	el := p.exprlist()
 
+	// labeled loop
+	if label, ok := el[0].(*ndIdent); ok && len(el) == 1 && p.iscur(tkColon) {
+		p.proceed()
+		p.skipnewline()
+		if !p.iscur(tkFor) {
+			panic("label must be followed by for loop")
+		}
+		return p._for(label.ident)
+	}
+
 	assignops := []tktype{tkEq, tkPlusEq, tkHyphenEq, tkStarEq, tkSlashEq, tkPercentEq, tkAmpEq, tkVBarEq, tkCaretEq, tkColonEq}
 	if ok, t := p.iscurin(assignops); ok {
 		n := &ndAssign{tok: p.cur, left: el}
@@ -256,54 +313,205 @@ func (p *parser) _if() node {
 	return n
 }
 
-// for = "for" expr ("," ident "in" expr)? block
-func (p *parser) _for() node {
+// for = (ident ":")? "for" ((ident ("," ident)? "in")? expr)? block
+func (p *parser) _for(label string) node {
 	p.skipnewline()
 	cur := p.cur
 
-	var n node
-
 	p.must(tkFor)
-	e := p.expr()
 
-	// if comma, loop the iterable object
-	if p.iscur(tkComma) {
-		nl := &ndLoop{tok: cur}
-		nl.cnt = e
-		p.must(tkComma)
-		nl.elem = p.ident()
-		p.must(tkIn)
-		nl.target = p.expr()
-		nl.blocks = p.block()
-		n = nl
-	} else {
-		nc := &ndCondLoop{tok: cur}
-		nc.cond = e
-		nc.blocks = p.block()
-		n = nc
+	p.labels = append(p.labels, label)
+	defer func() { p.labels = p.labels[:len(p.labels)-1] }()
+
+	// infinite loop
+	if p.iscur(tkLBrace) {
+		return &ndCondLoop{tok: cur, blocks: p.block(), label: label}
+	}
+
+	if cnt, elem, ok := p.forvars(); ok {
+		return &ndLoop{tok: cur, cnt: cnt, elem: elem, target: p.expr(), blocks: p.block(), label: label}
+	}
+
+	return &ndCondLoop{tok: cur, cond: p.expr(), blocks: p.block(), label: label}
+}
+
+// forvars reads `ident ("," ident)? "in"` which starts a loop over an iterable.
+// cnt is nil when the counter is omitted.
+// If the loop is not the form, it consumes nothing and returns false.
+func (p *parser) forvars() (cnt, elem node, ok bool) {
+	m := p.mark()
+	c := p.cur
+
+	if p.iscur(tkIdent) {
+		elem = p.ident()
+		if p.iscur(tkComma) {
+			p.proceed()
+			if p.iscur(tkIdent) {
+				cnt, elem = elem, p.ident()
+			} else {
+				cnt = nil
+				elem = nil
+			}
+		}
+
+		if elem != nil && p.iscur(tkIn) {
+			p.proceed()
+			return cnt, elem, true
+		}
+	}
+
+	p.reset(m)
+	p.cur = c
+	return nil, nil, false
+}
+
+// looplabel reads the optional label after break or continue.
+// The label must be of an enclosing loop.
+func (p *parser) looplabel() string {
+	if !p.iscur(tkIdent) {
+		return ""
 	}
 
+	label := p.cur.lit
+	for _, l := range p.labels {
+		if l == label {
+			p.proceed()
+			return label
+		}
+	}
+
+	panic(fmt.Sprintf("label %s is not defined", label))
+}
+
+// try = "try" block "catch" ident? block
+func (p *parser) _try() node {
+	p.skipnewline()
+	n := &ndTry{tok: p.cur}
+	p.must(tkTry)
+	n.blocks = p.block()
+	p.skipnewline()
+	p.must(tkCatch)
+	if p.iscur(tkIdent) {
+		n.errname = p.ident()
+	}
+	n.catchblocks = p.block()
 	return n
 }
 
-// def = "def" ident "(" expr-list? ")" block
+// match = "match" expr "{" ("case" expr ("if" expr)? block)* "}"
+func (p *parser) match() node {
+	p.skipnewline()
+	n := &ndMatch{tok: p.cur}
+	p.must(tkMatch)
+	n.target = p.expr()
+	p.must(tkLBrace)
+	p.skipnewline()
+
+	for !p.iscur(tkRBrace) {
+		c := &ndCase{tok: p.cur}
+		p.must(tkCase)
+		c.pattern = p.expr()
+		if p.iscur(tkIf) {
+			p.proceed()
+			c.guard = p.expr()
+		}
+		c.blocks = p.block()
+		n.cases = append(n.cases, c)
+		p.skipnewline()
+	}
+
+	p.proceed()
+	return n
+}
+
+// def = "def" ("(" ident ")")? ident "(" params? ")" block
 func (p *parser) def() node {
 	p.skipnewline()
 	n := &ndFunDef{tok: p.cur}
 	p.must(tkDef)
+	if p.iscur(tkLParen) {
+		p.proceed()
+		n.recv = p.ident().(*ndIdent).ident
+		p.must(tkRParen)
+	}
 	n.name = p.ident().(*ndIdent).ident
 	p.must(tkLParen)
 	p.skipnewline()
 
 	if !p.iscur(tkRParen) {
-		n.params = p.exprlist()
+		n.params = p.params()
 	}
 	p.must(tkRParen)
 
+	// loops outside of the function cannot be broken from inside
+	labels := p.labels
+	p.labels = nil
 	n.blocks = p.block()
+	p.labels = labels
 	return n
 }
 
+// params = param ("," param)*
+// param = ident (":" expr)? ("=" expr)? | "*" ident | "**" ident
+// Params with default must follow the ones without default, and "*" and "**" params must be the last.
+func (p *parser) params() []node {
+	params := []node{}
+	names := map[string]bool{}
+	var last *ndParam
+	for {
+		n := &ndParam{tok: p.cur}
+		if p.iscur(tkStar) {
+			p.proceed()
+			if p.iscur(tkStar) {
+				p.proceed()
+				n.kwvariadic = true
+			} else {
+				n.variadic = true
+			}
+		}
+
+		n.name = p.ident().(*ndIdent).ident
+		if !n.variadic && !n.kwvariadic && p.iscur(tkColon) {
+			p.proceed()
+			n.annot = p.expr()
+		}
+
+		if !n.variadic && !n.kwvariadic && p.iscur(tkEq) {
+			p.proceed()
+			p.skipnewline()
+			n.dflt = p.expr()
+		}
+
+		if names[n.name] {
+			panic(fmt.Sprintf("duplicate parameter %s", n.name))
+		}
+		names[n.name] = true
+
+		if last != nil {
+			switch {
+			case last.kwvariadic:
+				panic("**" + last.name + " must be the last parameter")
+			case last.variadic && !n.kwvariadic:
+				panic("only **param can follow *" + last.name)
+			case last.dflt != nil && n.dflt == nil && !n.variadic && !n.kwvariadic:
+				panic(fmt.Sprintf("parameter %s without default follows parameter with default", n.name))
+			}
+		}
+
+		params = append(params, n)
+		last = n
+
+		if !p.iscur(tkComma) {
+			break
+		}
+
+		p.proceed()
+		p.skipnewline()
+	}
+
+	return params
+}
+
 // struct = "struct" ident "{" ident-list? def-list? "}"
 func (p *parser) structdef() node {
 	p.skipnewline()
@@ -313,10 +521,6 @@ func (p *parser) structdef() node {
 	p.must(tkLBrace)
 	p.skipnewline()
 
-	if p.iscur(tkRBrace) {
-		return n
-	}
-
 	// read variables
 	for {
 		if p.iscur(tkDef) {
@@ -345,13 +549,83 @@ func (p *parser) structdef() node {
 	return n
 }
 
-// return = "return" expr
+// interface = "interface" ident "{" (ident "(" params? ")")* "}"
+func (p *parser) interfacedef() node {
+	p.skipnewline()
+	n := &ndInterfaceDef{tok: p.cur}
+	p.must(tkInterface)
+	n.name = p.ident()
+	p.must(tkLBrace)
+	p.skipnewline()
+
+	for !p.iscur(tkRBrace) {
+		fn := &ndFunDef{tok: p.cur}
+		fn.name = p.ident().(*ndIdent).ident
+		p.must(tkLParen)
+		p.skipnewline()
+		if !p.iscur(tkRParen) {
+			fn.params = p.params()
+		}
+		p.must(tkRParen)
+		n.fns = append(n.fns, fn)
+		p.skipnewline()
+	}
+
+	p.must(tkRBrace)
+	return n
+}
+
+// enum = "enum" ident "{" (variant ","?)* "}"
+// variant = ident ("(" ident ("," ident)* ")")?
+func (p *parser) enumdef() node {
+	p.skipnewline()
+	n := &ndEnumDef{tok: p.cur}
+	p.must(tkEnum)
+	n.name = p.ident()
+	p.must(tkLBrace)
+	p.skipnewline()
+
+	for !p.iscur(tkRBrace) {
+		v := &ndVariant{tok: p.cur}
+		v.name = p.ident().(*ndIdent).ident
+		if p.iscur(tkLParen) {
+			p.proceed()
+			for {
+				p.skipnewline()
+				v.fields = append(v.fields, p.ident().(*ndIdent).ident)
+				p.skipnewline()
+				if !p.iscur(tkComma) {
+					break
+				}
+				p.proceed()
+			}
+			p.must(tkRParen)
+		}
+		n.variants = append(n.variants, v)
+
+		if p.iscur(tkComma) {
+			p.proceed()
+		}
+		p.skipnewline()
+	}
+
+	p.must(tkRBrace)
+	return n
+}
+
+// return = "return" expr-list?
 func (p *parser) _return() node {
 	p.skipnewline()
 	n := &ndReturn{tok: p.cur}
 	p.must(tkReturn)
 	if !p.iscur(tkNewLine) {
-		n.val = p.expr()
+		// "return a, b" returns a tuple
+		vals := p.exprlist()
+		if len(vals) == 1 {
+			n.val = vals[0]
+		} else {
+			n.val = &ndTuple{tok: vals[0].token(), vals: vals}
+		}
 	}
 
 	return n
@@ -361,9 +635,24 @@ func (p *parser) _return() node {
  * expression
  */
 
-// expr = logor
+// expr = coalesce
 func (p *parser) expr() node {
-	return p.logor()
+	return p.coalesce()
+}
+
+// coalesce = logor ("??" logor)*
+func (p *parser) coalesce() node {
+	n := p.logor()
+	for p.iscur(tk2Question) {
+		n2 := newbinaryop(p.cur, boCoalesce)
+		p.proceed()
+		p.skipnewline()
+		n2.left = n
+		n2.right = p.logor()
+		n = n2
+	}
+
+	return n
 }
 
 // logor = logand ("||" logand)*
@@ -498,7 +787,7 @@ func (p *parser) equality() node {
 	return n
 }
 
-// relational = shift ("<" shift | "<=" shift | ">" shift | ">=" shift)*
+// relational = shift ("<" shift | "<=" shift | ">" shift | ">=" shift | "in" shift | "not" "in" shift)*
 func (p *parser) relational() node {
 	n := p.shift()
 	for {
@@ -542,6 +831,27 @@ func (p *parser) relational() node {
 			continue
 		}
 
+		if p.iscur(tkIn) {
+			n2 := newbinaryop(p.cur, boIn)
+			p.proceed()
+			p.skipnewline()
+			n2.left = n
+			n2.right = p.shift()
+			n = n2
+			continue
+		}
+
+		if p.iscur(tkNot) {
+			n2 := newbinaryop(p.cur, boNotIn)
+			p.proceed()
+			p.must(tkIn)
+			p.skipnewline()
+			n2.left = n
+			n2.right = p.shift()
+			n = n2
+			continue
+		}
+
 		break
 	}
 
@@ -692,8 +1002,8 @@ func (p *parser) postfix() node {
 	n := p.primary()
 
 	for {
-		if p.iscur(tkDot) {
-			n2 := &ndSelector{tok: p.cur}
+		if p.iscur(tkDot) || p.iscur(tkQuestDot) {
+			n2 := &ndSelector{tok: p.cur, optional: p.iscur(tkQuestDot)}
 			p.proceed()
 			p.skipnewline()
 			n2.selector = n
@@ -705,10 +1015,15 @@ func (p *parser) postfix() node {
 		if p.iscur(tkLBracket) {
 			p.proceed()
 			p.skipnewline()
-			e := p.expr()
+
+			// start of the slice can be omitted
+			var e node
+			if !p.iscur(tkColon) {
+				e = p.expr()
+			}
 
 			// index
-			if p.iscur(tkRBracket) {
+			if e != nil && p.iscur(tkRBracket) {
 				n2 := &ndIndex{tok: p.cur}
 				p.proceed()
 				p.skipnewline()
@@ -721,12 +1036,20 @@ func (p *parser) postfix() node {
 			// slice
 			p.must(tkColon)
 			p.skipnewline()
-			n2 := &ndSlice{tok: p.cur}
-			e2 := p.expr()
+			n2 := &ndSlice{tok: p.cur, start: e, target: n}
+			if !p.iscur(tkColon) && !p.iscur(tkRBracket) {
+				n2.end = p.expr()
+			}
+
+			if p.iscur(tkColon) {
+				p.proceed()
+				p.skipnewline()
+				if !p.iscur(tkRBracket) {
+					n2.step = p.expr()
+				}
+			}
+
 			p.must(tkRBracket)
-			n2.start = e
-			n2.end = e2
-			n2.target = n
 			n = n2
 			continue
 		}
@@ -737,7 +1060,7 @@ func (p *parser) postfix() node {
 			p.skipnewline()
 			n2.fn = n
 			if !p.iscur(tkRParen) {
-				n2.args = p.exprlist()
+				n2.args = p.args()
 			}
 			p.must(tkRParen)
 			n = n2
@@ -751,23 +1074,65 @@ func (p *parser) postfix() node {
 	return n
 }
 
-// primary = list | dict | "(" expr ")" | str | num | "true" | "false" | ident | struct_init
+// args = arg ("," arg)*
+// arg = expr | ident "=" expr | "*" expr | "**" expr
+// Positional args cannot follow keyword args.
+func (p *parser) args() []node {
+	args := []node{}
+	kw := false
+	for {
+		var n node
+		if p.iscur(tkStar) {
+			sp := &ndSpread{tok: p.cur}
+			p.proceed()
+			if p.iscur(tkStar) {
+				p.proceed()
+				sp.kw = true
+				kw = true
+			}
+			sp.target = p.expr()
+			n = sp
+		} else {
+			tok := p.cur
+			e := p.expr()
+			if i, ok := e.(*ndIdent); ok && p.iscur(tkEq) {
+				p.proceed()
+				p.skipnewline()
+				n = &ndKwArg{tok: tok, name: i.ident, val: p.expr()}
+				kw = true
+			} else {
+				if kw {
+					panic("positional argument follows keyword argument")
+				}
+				n = e
+			}
+		}
+
+		args = append(args, n)
+
+		if !p.iscur(tkComma) {
+			break
+		}
+
+		p.proceed()
+		p.skipnewline()
+	}
+
+	return args
+}
+
+// primary = list | dictorset | paren | str | fstr | num | "true" | "false" | "nil" | ident | struct_init
 func (p *parser) primary() node {
 	if p.iscur(tkLBracket) {
 		return p.list()
 	}
 
 	if p.iscur(tkLBrace) {
-		return p.dict()
+		return p.dictorset()
 	}
 
 	if p.iscur(tkLParen) {
-		p.proceed()
-		p.skipnewline()
-		n := p.expr()
-		p.must(tkRParen)
-		p.skipnewline()
-		return n
+		return p.paren()
 	}
 
 	if p.iscur(tkStr) {
@@ -777,26 +1142,12 @@ func (p *parser) primary() node {
 		return n
 	}
 
-	if p.iscur(tkNum) {
-		s := p.cur.lit
-
-		i, err := strconv.ParseInt(s, 10, 64)
-		if err == nil {
-			n := &ndI64{tok: p.cur}
-			n.val = i
-			p.proceed()
-			return n
-		}
-
-		f, err := strconv.ParseFloat(s, 64)
-		if err == nil {
-			n := &ndF64{tok: p.cur}
-			n.val = f
-			p.proceed()
-			return n
-		}
+	if p.iscur(tkFStr) {
+		return p.fstr()
+	}
 
-		panic(fmt.Sprintf("parse %s as number", s))
+	if p.iscur(tkNum) {
+		return p.num()
 	}
 
 	if p.iscur(tkTrue) {
@@ -813,6 +1164,12 @@ func (p *parser) primary() node {
 		return n
 	}
 
+	if p.iscur(tkNil) {
+		n := &ndNil{tok: p.cur}
+		p.proceed()
+		return n
+	}
+
 	i := p.ident()
 	if !p.iscur(tkLBrace) {
 		return i
@@ -831,6 +1188,132 @@ func (p *parser) primary() node {
 	return i
 }
 
+// paren = "(" ")" | "(" expr ")" | "(" expr "," (expr ("," expr)* ","?)? ")"
+// Parenthesized expr with comma is a tuple. "(a,)" is the tuple with single element.
+func (p *parser) paren() node {
+	tok := p.cur
+	p.must(tkLParen)
+	p.skipnewline()
+
+	if p.iscur(tkRParen) {
+		p.proceed()
+		return &ndTuple{tok: tok}
+	}
+
+	n := p.expr()
+	p.skipnewline()
+	if !p.iscur(tkComma) {
+		p.must(tkRParen)
+		p.skipnewline()
+		return n
+	}
+
+	t := &ndTuple{tok: tok, vals: []node{n}}
+	for p.iscur(tkComma) {
+		p.proceed()
+		p.skipnewline()
+		if p.iscur(tkRParen) {
+			break
+		}
+		t.vals = append(t.vals, p.expr())
+		p.skipnewline()
+	}
+
+	p.must(tkRParen)
+	return t
+}
+
+// fstr parses the expressions and the format specs in f-string.
+func (p *parser) fstr() node {
+	n := &ndFStr{tok: p.cur}
+	for _, part := range p.cur.fparts {
+		if part.expr == nil {
+			n.parts = append(n.parts, &ndStr{tok: p.cur, val: part.lit})
+			continue
+		}
+
+		sub := newsubparser(p.tokenizer.tr.mod, part.expr, part.loc)
+		e := sub.fstrexpr()
+
+		spec, err := parsefmtspec(part.spec)
+		if err != nil {
+			panic(newsberr2(part.specloc, "%s", err))
+		}
+
+		n.parts = append(n.parts, &ndFormat{tok: e.token(), target: e, spec: spec})
+	}
+
+	p.proceed()
+	return n
+}
+
+// fstrexpr parses the expression in f-string.
+// Syntax error is raised with the location in the expression.
+func (p *parser) fstrexpr() (n node) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(shibaErr); ok {
+				panic(e)
+			}
+
+			panic(newsberr2(p.cur.loc, "%v", r))
+		}
+	}()
+
+	n = p.expr()
+	if !p.iscur(tkEof) {
+		panic(fmt.Sprintf("unexpected %s in f-string", p.cur.typ))
+	}
+
+	return n
+}
+
+// num converts the number literal to i64, bigint or f64.
+// The literal is already validated in tokenreader.
+func (p *parser) num() node {
+	tok := p.cur
+	lit := strings.ReplaceAll(tok.lit, "_", "")
+
+	base := 10
+	if len(lit) > 2 && lit[0] == '0' {
+		switch lit[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+
+		if base != 10 {
+			lit = lit[2:]
+		}
+	}
+
+	if base != 10 || !strings.ContainsAny(lit, ".eE") {
+		if i, err := strconv.ParseInt(lit, base, 64); err == nil {
+			p.proceed()
+			return &ndI64{tok: tok, val: i}
+		}
+
+		// integer literal which does not fit in i64
+		if b, ok := new(big.Int).SetString(lit, base); ok {
+			p.proceed()
+			return &ndBigInt{tok: tok, val: b}
+		}
+
+		panic(fmt.Sprintf("parse %s as number", tok.lit))
+	}
+
+	f, err := strconv.ParseFloat(lit, 64)
+	if err != nil {
+		panic(fmt.Sprintf("%s is out of range of f64", tok.lit))
+	}
+
+	p.proceed()
+	return &ndF64{tok: tok, val: f}
+}
+
 func (p *parser) try(f func() node) (n node) {
 	m := p.mark()
 	c := p.cur
@@ -856,6 +1339,34 @@ func (p *parser) list() node {
 	return n
 }
 
+// dictorset = dict | "{" expr-list "}"
+// "{}" is an empty dict. The empty set is "set()".
+// The first expr decides which one it is: dict if ":" follows, otherwise set.
+func (p *parser) dictorset() node {
+	tok := p.cur
+	p.must(tkLBrace)
+	p.skipnewline()
+	if p.iscur(tkRBrace) {
+		p.proceed()
+		return &ndDict{tok: tok}
+	}
+
+	first := p.expr()
+	if p.iscur(tkColon) {
+		return p.dictitems(&ndDict{tok: tok}, first)
+	}
+
+	n := &ndSet{tok: tok, vals: []node{first}}
+	if p.iscur(tkComma) {
+		p.proceed()
+		p.skipnewline()
+		n.vals = append(n.vals, p.exprlist()...)
+	}
+	p.skipnewline()
+	p.must(tkRBrace)
+	return n
+}
+
 // dict = "{}" | "{" expr ":" expr ("," expr ":" expr)* "}"
 func (p *parser) dict() node {
 	n := &ndDict{tok: p.cur}
@@ -866,17 +1377,22 @@ func (p *parser) dict() node {
 	}
 
 	p.skipnewline()
+	return p.dictitems(n, p.expr())
+}
+
+// dictitems reads the rest of the dict after the first key is read.
+func (p *parser) dictitems(n *ndDict, key node) node {
 	for {
-		n.keys = append(n.keys, p.expr())
+		n.keys = append(n.keys, key)
 		p.must(tkColon)
 		n.vals = append(n.vals, p.expr())
 
-		if p.iscur(tkComma) {
-			p.proceed()
-			continue
+		if !p.iscur(tkComma) {
+			break
 		}
 
-		break
+		p.proceed()
+		key = p.expr()
 	}
 
 	p.must(tkRBrace)
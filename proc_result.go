@@ -12,22 +12,28 @@ type prNop struct{}
 
 func (p *prNop) String() string { return "nop" }
 
-type prContinue struct{}
+// label is empty when the innermost loop is continued.
+type prContinue struct {
+	label string
+}
 
 func (p *prContinue) String() string { return "continue" }
 
-type prBreak struct{}
+// label is empty when the innermost loop is broken.
+type prBreak struct {
+	label string
+}
 
 func (p *prBreak) String() string { return "break" }
 
 type prReturn struct {
-	ret *obj
+	ret obj
 }
 
 func (p *prReturn) String() string { return "return" }
 
 type prObj struct {
-	o *obj
+	o obj
 }
 
 func (p *prObj) String() string { return "obj" }
@@ -2,10 +2,11 @@ package main
 
 import (
 	"path/filepath"
+	"strings"
 )
 
-func procAsObj(mod *module, n node) (*obj, shibaErr) {
-	pr, err := process(mod, n)
+func procAsObj(env *environment, mod *module, n node) (obj, shibaErr) {
+	pr, err := process(env, mod, n)
 	if err != nil {
 		return nil, err
 	}
@@ -18,7 +19,7 @@ func procAsObj(mod *module, n node) (*obj, shibaErr) {
 	return o.o, nil
 }
 
-func process(mod *module, nd node) (procResult, shibaErr) {
+func process(env *environment, mod *module, nd node) (procResult, shibaErr) {
 	switch n := nd.(type) {
 	case *ndEof:
 		return &prExit{}, nil
@@ -27,87 +28,120 @@ func process(mod *module, nd node) (procResult, shibaErr) {
 		return &prNop{}, nil
 
 	case *ndBreak:
-		return &prBreak{}, nil
+		return &prBreak{label: n.label}, nil
 
 	case *ndContinue:
-		return &prContinue{}, nil
+		return &prContinue{label: n.label}, nil
 
 	case *ndReturn:
-		return procReturn(mod, n)
+		return procReturn(env, mod, n)
 
 	case *ndAssign:
-		return procAssign(mod, n)
+		return procAssign(env, mod, n)
 
 	case *ndIf:
-		return procIf(mod, n)
+		return procIf(env, mod, n)
+
+	case *ndTry:
+		return procTry(env, mod, n)
+
+	case *ndMatch:
+		return procMatch(env, mod, n)
+
+	case *ndDel:
+		return procDel(env, mod, n)
 
 	case *ndLoop:
-		return procLoop(mod, n)
+		return procLoop(env, mod, n)
 
 	case *ndCondLoop:
-		return procCondLoop(mod, n)
+		return procCondLoop(env, mod, n)
 
 	case *ndStructDef:
-		return procStructDef(mod, n)
+		return procStructDef(env, mod, n)
 
 	case *ndStructInit:
-		return procStructInit(mod, n)
+		return procStructInit(env, mod, n)
+
+	case *ndInterfaceDef:
+		return procInterfaceDef(env, mod, n)
+
+	case *ndEnumDef:
+		return procEnumDef(env, mod, n)
 
 	case *ndFunDef:
-		return procFunDef(mod, n)
+		return procFunDef(env, mod, n)
 
 	case *ndIndex:
-		return procIndex(mod, n)
+		return procIndex(env, mod, n)
 
 	case *ndSlice:
-		return procSlice(mod, n)
+		return procSlice(env, mod, n)
 
 	case *ndSelector:
-		return procSelector(mod, n)
+		return procSelector(env, mod, n)
 
 	case *ndFuncall:
-		return procFuncall(mod, n)
+		return procFuncall(env, mod, n)
 
 	case *ndImport:
-		return procImport(mod, n)
+		return procImport(env, mod, n)
 
 	case *ndBinaryOp:
-		return procBinaryOp(mod, n)
+		return procBinaryOp(env, mod, n)
 
 	case *ndUnaryOp:
-		return procUnaryOp(mod, n)
+		return procUnaryOp(env, mod, n)
 
 	case *ndList:
-		return procList(mod, n)
+		return procList(env, mod, n)
+
+	case *ndTuple:
+		return procTuple(env, mod, n)
+
+	case *ndSet:
+		return procSet(env, mod, n)
 
 	case *ndDict:
-		return procDict(mod, n)
+		return procDict(env, mod, n)
 
 	case *ndIdent:
-		return procIdent(mod, n)
+		return procIdent(env, mod, n)
 
 	case *ndStr:
-		return &prObj{o: &obj{typ: tStr, bytes: []byte(n.val)}}, nil
+		return &prObj{o: newstr(n.val)}, nil
+
+	case *ndNil:
+		return &prObj{o: NIL}, nil
+
+	case *ndFStr:
+		return procFStr(env, mod, n)
+
+	case *ndFormat:
+		return procFormat(env, mod, n)
 
 	case *ndI64:
-		return &prObj{o: &obj{typ: tI64, ival: n.val}}, nil
+		return &prObj{o: &oI64{val: n.val}}, nil
+
+	case *ndBigInt:
+		return &prObj{o: newint(n.val)}, nil
 
 	case *ndF64:
-		return &prObj{o: &obj{typ: tF64, fval: n.val}}, nil
+		return &prObj{o: &oF64{val: n.val}}, nil
 
 	case *ndBool:
-		return &prObj{o: &obj{typ: tBool, bval: n.val}}, nil
+		return &prObj{o: newbool(n.val)}, nil
 	}
 
 	return nil, newinterr(nd, "unhandled nodetype: %s", nd)
 }
 
-func procReturn(mod *module, n *ndReturn) (procResult, shibaErr) {
+func procReturn(env *environment, mod *module, n *ndReturn) (procResult, shibaErr) {
 	if n.val == nil {
-		return &prReturn{}, nil
+		return &prReturn{ret: NIL}, nil
 	}
 
-	o, err := procAsObj(mod, n.val)
+	o, err := procAsObj(env, mod, n.val)
 	if err != nil {
 		return nil, err
 	}
@@ -115,31 +149,31 @@ func procReturn(mod *module, n *ndReturn) (procResult, shibaErr) {
 	return &prReturn{ret: o}, nil
 }
 
-func procAssign(mod *module, n *ndAssign) (procResult, shibaErr) {
+func procAssign(env *environment, mod *module, n *ndAssign) (procResult, shibaErr) {
 	if n.op == aoUnpackEq {
-		return procUnpackAssign(mod, n)
+		return procUnpackAssign(env, mod, n)
 	}
 
 	if n.op != aoEq {
-		return procComputeAssign(mod, n)
+		return procComputeAssign(env, mod, n)
 	}
 
-	return procPlainAssign(mod, n)
+	return procPlainAssign(env, mod, n)
 }
 
 // plain assign assigns multiple right values to multiple left operand.
-func procPlainAssign(mod *module, n *ndAssign) (procResult, shibaErr) {
+func procPlainAssign(env *environment, mod *module, n *ndAssign) (procResult, shibaErr) {
 	if len(n.left) != len(n.right) {
 		return nil, newsberr(n, "assignment size mismatch")
 	}
 
 	for i := range n.left {
-		r, err := procAsObj(mod, n.right[i])
+		r, err := procAsObj(env, mod, n.right[i])
 		if err != nil {
 			return nil, err
 		}
 
-		if err := assignTo(mod, n.left[i], r); err != nil {
+		if err := assignTo(env, mod, n.left[i], r); err != nil {
 			return nil, err
 		}
 	}
@@ -147,69 +181,149 @@ func procPlainAssign(mod *module, n *ndAssign) (procResult, shibaErr) {
 	return nil, nil
 }
 
-func assignTo(mod *module, dst node, o *obj) shibaErr {
-	d, err := procAsObj(mod, dst)
-	// when err is nil, the node is already defined. update it
-	if err == nil {
-		d.update(o)
+func assignTo(env *environment, mod *module, dst node, o obj) shibaErr {
+	switch d := dst.(type) {
+	case *ndIdent:
+		env.setobj(mod, d.ident, o)
 		return nil
-	}
 
-	// if left is undefined, create a new var
-	if _, ok := err.(*errUndefinedIdent); ok {
-		ident, ok := dst.(*ndIdent)
-		if !ok {
+	case *ndIndex:
+		tgt, err := procAsObj(env, mod, d.target)
+		if err != nil {
 			return err
 		}
-		env.setobj(mod, ident.ident, o)
-		return nil
-	}
 
-	// if left is dict with key and the key is not found,
-	// create a new key in the dict
-	if _, ok := err.(*errDictKeyNotFound); ok {
-		index, ok := dst.(*ndIndex)
-		if !ok {
+		idx, err := procAsObj(env, mod, d.idx)
+		if err != nil {
 			return err
 		}
 
-		oDict, err := procAsObj(mod, index.target)
+		switch t := tgt.(type) {
+		case *oDict:
+			// if the key is not found, a new key is created in the dict
+			if err := t.dict.set(idx, o); err != nil {
+				return sberrof(d.idx, err)
+			}
+			return nil
+
+		case *oList:
+			i, ok := idx.(*oI64)
+			if !ok {
+				return newTypeMismatchErr(d, "i64", idx)
+			}
+
+			seq := t.sequence().(mutableSequence)
+			ni, nerr := normindex(int(i.val), seq.size())
+			if nerr != nil {
+				return newsberr(d, "%s", nerr)
+			}
+
+			seq.setindex(ni, o)
+			return nil
+
+		case *oStruct:
+			_, ok, serr := t.def.callmethod(t, "SetIndex", idx, o)
+			if serr != nil {
+				return sberrof(d, serr)
+			}
+
+			if ok {
+				return nil
+			}
+		}
+
+		return newsberr(d, "cannot assign to index of %s", tgt.typename())
+
+	case *ndSlice:
+		tgt, err := procAsObj(env, mod, d.target)
 		if err != nil {
 			return err
 		}
 
-		if oDict.typ != tDict {
-			return err
+		l, ok := tgt.(*oList)
+		if !ok {
+			return newsberr(d, "cannot assign to slice of %s", tgt.typename())
+		}
+
+		vals, ok, eerr := elems(o)
+		if eerr != nil {
+			return sberrof(d, eerr)
 		}
 
-		oIndex, err := procAsObj(mod, index.idx)
+		if !ok {
+			return newsberr(d, "cannot assign %s to slice", o.typename())
+		}
+
+		seq := l.sequence().(mutableSequence)
+		start, end, step, err := procSliceIndices(env, mod, d, seq.size())
 		if err != nil {
 			return err
 		}
 
-		oDict.dict.set(oIndex, o)
+		if serr := seq.setslice(start, end, step, vals); serr != nil {
+			return newsberr(d, "%s", serr)
+		}
 
 		return nil
+
+	case *ndSelector:
+		if !d.target.isexported() {
+			return newsberr(d, "%s is unexported", d.target)
+		}
+
+		field, ok := d.target.(*ndIdent)
+		if !ok {
+			return newsberr(d, "%s must be an identifier", d.target)
+		}
+
+		selector, err := procAsObj(env, mod, d.selector)
+		if err != nil {
+			return err
+		}
+
+		switch s := selector.(type) {
+		case *oMod:
+			if _, ok := s.mod.globscope.getglobobj(field.ident); !ok {
+				return newsberr(d, "%s is undefined in module %s", field.ident, s.mod.name)
+			}
+
+			s.mod.globscope.setobj(field.ident, o)
+			return nil
+
+		case *oStruct:
+			holder, err := s.lookup(field.ident)
+			if err != nil {
+				return newsberr(d, "%s", err)
+			}
+
+			if holder == nil || !holder.def.hasfield(field.ident) {
+				return newsberr(d, "unknown field name %s in %s", field.ident, s)
+			}
+
+			holder.fields[field.ident] = o
+			return nil
+		}
+
+		return newsberr(d, "selector %s is not a module or struct", selector)
 	}
 
-	// other error
-	return err
+	return newsberr(dst, "cannot assign to %s", dst)
 }
 
 // unpack assign unpacks right side operator to the left.
 // Right side must have only one iterable operand.
 // The left side size must be the same with right side iterable size.
-func procUnpackAssign(mod *module, n *ndAssign) (procResult, shibaErr) {
+func procUnpackAssign(env *environment, mod *module, n *ndAssign) (procResult, shibaErr) {
 	if len(n.right) != 1 {
 		return nil, newsberr(n, ":= cannot have multiple operands on right side")
 	}
 
-	r, err := procAsObj(mod, n.right[0])
+	r, err := procAsObj(env, mod, n.right[0])
 	if err != nil {
 		return nil, err
 	}
 
-	if !r.cansequence() {
+	if !r.isSequencable() {
 		return nil, newsberr(n, "cannot unpack %s", r)
 	}
 
@@ -219,7 +333,7 @@ func procUnpackAssign(mod *module, n *ndAssign) (procResult, shibaErr) {
 	}
 
 	for i := range n.left {
-		if err := assignTo(mod, n.left[i], seq.index(i)); err != nil {
+		if err := assignTo(env, mod, n.left[i], seq.index(i)); err != nil {
 			return nil, err
 		}
 	}
@@ -227,7 +341,7 @@ func procUnpackAssign(mod *module, n *ndAssign) (procResult, shibaErr) {
 	return nil, nil
 }
 
-func procComputeAssign(mod *module, n *ndAssign) (procResult, shibaErr) {
+func procComputeAssign(env *environment, mod *module, n *ndAssign) (procResult, shibaErr) {
 	if len(n.left) != 1 {
 		return nil, newsberr(n, "left must be only one operand on %s", n.op)
 	}
@@ -259,12 +373,12 @@ func procComputeAssign(mod *module, n *ndAssign) (procResult, shibaErr) {
 		bo = boBitwiseXor
 	}
 
-	l, err := procAsObj(mod, left)
+	l, err := procAsObj(env, mod, left)
 	if err != nil {
 		return nil, err
 	}
 
-	r, err := procAsObj(mod, right)
+	r, err := procAsObj(env, mod, right)
 	if err != nil {
 		return nil, err
 	}
@@ -274,27 +388,30 @@ func procComputeAssign(mod *module, n *ndAssign) (procResult, shibaErr) {
 		return nil, newsberr(n, "invalid assignment: %s %s %s", left, bo, right)
 	}
 
-	l.update(o)
+	if err := assignTo(env, mod, left, o); err != nil {
+		return nil, err
+	}
+
 	return nil, nil
 }
 
-func procIf(mod *module, n *ndIf) (procResult, shibaErr) {
+func procIf(env *environment, mod *module, n *ndIf) (procResult, shibaErr) {
 	env.createblockscope(mod)
 	defer env.delblockscope(mod)
 
 	for i := range n.conds {
-		cond, err := procAsObj(mod, n.conds[i])
+		cond, err := procAsObj(env, mod, n.conds[i])
 		if err != nil {
 			return nil, err
 		}
 
-		if !cond.isTruthy() {
+		if !cond.isTruethy() {
 			continue
 		}
 
 		// when condition is true, exec the block and exit
 		for _, block := range n.blocks[i] {
-			pr, err := process(mod, block)
+			pr, err := process(env, mod, block)
 			if err != nil {
 				return nil, err
 			}
@@ -318,125 +435,389 @@ func procIf(mod *module, n *ndIf) (procResult, shibaErr) {
 	return nil, nil
 }
 
-func procLoop(mod *module, n *ndLoop) (procResult, shibaErr) {
+// runblock runs the statements until return, break or continue appears.
+func runblock(env *environment, mod *module, blocks []node) (procResult, shibaErr) {
+	for _, block := range blocks {
+		pr, err := process(env, mod, block)
+		if err != nil {
+			return nil, err
+		}
+
+		switch pr.(type) {
+		case *prReturn, *prBreak, *prContinue:
+			return pr, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func procTry(env *environment, mod *module, n *ndTry) (procResult, shibaErr) {
 	env.createblockscope(mod)
-	defer env.delblockscope(mod)
+	pr, err := runblock(env, mod, n.blocks)
+	env.delblockscope(mod)
+	if err == nil {
+		return pr, nil
+	}
 
-	if _, ok := n.cnt.(*ndIdent); !ok {
-		return nil, newsberr(n, "invalid counter %s in loop", n.cnt)
+	// exit() is not an error, so it is not caught
+	if _, ok := err.(*errExit); ok {
+		return nil, err
 	}
 
-	if _, ok := n.elem.(*ndIdent); !ok {
-		return nil, newsberr(n, "invalid element %s in loop", n.cnt)
+	env.createblockscope(mod)
+	defer env.delblockscope(mod)
+
+	if n.errname != nil {
+		env.defobj(mod, n.errname.(*ndIdent).ident, newstr(err.Error()))
 	}
 
-	target, err := procAsObj(mod, n.target)
+	return runblock(env, mod, n.catchblocks)
+}
+
+func procMatch(env *environment, mod *module, n *ndMatch) (procResult, shibaErr) {
+	target, err := procAsObj(env, mod, n.target)
 	if err != nil {
 		return nil, err
 	}
 
-	if !target.isiterable() {
-		return nil, newsberr(n, "non-iterable loop target")
+	// the first matched case runs. If nothing matches, nothing happens.
+	for _, c := range n.cases {
+		pr, matched, err := procCase(env, mod, c, target)
+		if err != nil || matched {
+			return pr, err
+		}
 	}
 
-	iter := target.iterator()
-	for iter.hasnext() {
-		next, i := iter.next()
-		env.setobj(mod, n.cnt.(*ndIdent).ident, &obj{typ: tI64, ival: int64(i)})
-		env.setobj(mod, n.elem.(*ndIdent).ident, next)
+	return nil, nil
+}
+
+// procCase runs the block if the target matches the pattern and the guard.
+// The names bound by the pattern are visible only in the guard and the block.
+func procCase(env *environment, mod *module, c *ndCase, target obj) (procResult, bool, shibaErr) {
+	env.createblockscope(mod)
+	defer env.delblockscope(mod)
+
+	ok, err := matchpattern(env, mod, c.pattern, target)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	if c.guard != nil {
+		guard, err := procAsObj(env, mod, c.guard)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if !guard.isTruethy() {
+			return nil, false, nil
+		}
+	}
+
+	pr, err := runblock(env, mod, c.blocks)
+	return pr, true, err
+}
+
+// matchpattern reports if the obj matches the pattern. The names captured by the pattern are defined in the current scope.
+// An identifier captures anything, but "_" does not bind.
+// List, dict and struct literals destructure the obj and match each element with the sub pattern.
+// Other expressions are evaluated and compared with the obj.
+func matchpattern(env *environment, mod *module, pattern node, o obj) (bool, shibaErr) {
+	switch pat := pattern.(type) {
+	case *ndFuncall:
+		// Enum.Variant(a, b) destructures the payload of the enum value.
+		// Other calls are evaluated and compared as below.
+		fn, err := procAsObj(env, mod, pat.fn)
+		if err != nil {
+			return false, err
+		}
+
+		v, ok := fn.(*oVariant)
+		if !ok {
+			break
+		}
+
+		if len(pat.args) != len(v.params) {
+			return false, newsberr(pat, "wrong number of fields in pattern %s: want %d but got %d", v, len(v.params), len(pat.args))
+		}
+
+		ev, ok := o.(*oEnumValue)
+		if !ok || ev.variant != v {
+			return false, nil
+		}
+
+		for i := range pat.args {
+			if ok, err := matchpattern(env, mod, pat.args[i], ev.vals[i]); err != nil || !ok {
+				return false, err
+			}
+		}
+
+		return true, nil
+
+	case *ndIdent:
+		if pat.ident != "_" {
+			env.defobj(mod, pat.ident, o)
+		}
+		return true, nil
+
+	case *ndList:
+		l, ok := o.(*oList)
+		if !ok || len(l.vals) != len(pat.vals) {
+			return false, nil
+		}
 
-		for _, block := range n.blocks {
-			pr, err := process(mod, block)
+		for i := range pat.vals {
+			if ok, err := matchpattern(env, mod, pat.vals[i], l.vals[i]); err != nil || !ok {
+				return false, err
+			}
+		}
+
+		return true, nil
+
+	case *ndTuple:
+		t, ok := o.(*oTuple)
+		if !ok || len(t.vals) != len(pat.vals) {
+			return false, nil
+		}
+
+		for i := range pat.vals {
+			if ok, err := matchpattern(env, mod, pat.vals[i], t.vals[i]); err != nil || !ok {
+				return false, err
+			}
+		}
+
+		return true, nil
+
+	case *ndDict:
+		// the keys in the pattern must exist, but the other keys in the dict are ignored.
+		d, ok := o.(*oDict)
+		if !ok {
+			return false, nil
+		}
+
+		for i := range pat.keys {
+			key, err := procAsObj(env, mod, pat.keys[i])
 			if err != nil {
-				return nil, err
+				return false, err
 			}
 
-			if _, ok := pr.(*prReturn); ok {
-				return pr, nil
+			v, ok, gerr := d.dict.get(key)
+			if gerr != nil {
+				return false, sberrof(pat.keys[i], gerr)
 			}
 
-			if _, ok := pr.(*prBreak); ok {
-				// when break, exit loop itself
-				return nil, nil
+			if !ok {
+				return false, nil
 			}
 
-			if _, ok := pr.(*prContinue); ok {
-				// when continue, exit running the block
-				break
+			if ok, err := matchpattern(env, mod, pat.vals[i], v); err != nil || !ok {
+				return false, err
+			}
+		}
+
+		return true, nil
+
+	case *ndStructInit:
+		name, ok := pat.name.(*ndIdent)
+		if !ok {
+			return false, newsberr(pat, "invalid struct name %s", pat.name)
+		}
+
+		sd, ok := env.getstruct(mod, name.ident)
+		if !ok {
+			return false, newsberr(pat, "struct %s is not defined", name.ident)
+		}
+
+		s, ok := o.(*oStruct)
+		if !ok || s.def != sd {
+			return false, nil
+		}
+
+		d, ok := pat.values.(*ndDict)
+		if !ok {
+			return false, newinterr(pat, "dict expected in struct pattern but got %s", pat.values)
+		}
+
+		for i := range d.keys {
+			field, ok := d.keys[i].(*ndIdent)
+			if !ok {
+				return false, newsberr(pat, "invalid field name %s in struct %s", d.keys[i], name.ident)
+			}
+
+			// the field can be promoted from the embedded struct
+			holder, err := s.lookup(field.ident)
+			if err != nil {
+				return false, newsberr(field, "%s", err)
+			}
+
+			if holder == nil || !holder.def.hasfield(field.ident) {
+				return false, newsberr(pat, "struct %s does not have field %s", name.ident, field.ident)
+			}
+
+			v, ok := holder.fields[field.ident]
+			if !ok {
+				return false, nil
+			}
+
+			if ok, err := matchpattern(env, mod, d.vals[i], v); err != nil || !ok {
+				return false, err
 			}
 		}
+
+		return true, nil
 	}
 
-	return nil, nil
+	v, err := procAsObj(env, mod, pattern)
+	if err != nil {
+		return false, err
+	}
+
+	eq, eqerr := v.equals(o)
+	if eqerr != nil {
+		return false, sberrof(pattern, eqerr)
+	}
+
+	return eq, nil
 }
 
-func procCondLoop(mod *module, n *ndCondLoop) (procResult, shibaErr) {
+func procLoop(env *environment, mod *module, n *ndLoop) (procResult, shibaErr) {
 	env.createblockscope(mod)
 	defer env.delblockscope(mod)
 
-	cond, err := procAsObj(mod, n.cond)
+	if n.cnt != nil {
+		if _, ok := n.cnt.(*ndIdent); !ok {
+			return nil, newsberr(n, "invalid counter %s in loop", n.cnt)
+		}
+	}
+
+	if _, ok := n.elem.(*ndIdent); !ok {
+		return nil, newsberr(n, "invalid element %s in loop", n.elem)
+	}
+
+	target, err := procAsObj(env, mod, n.target)
 	if err != nil {
 		return nil, err
 	}
 
-	for cond.isTruthy() {
-		for _, block := range n.blocks {
-			pr, err := process(mod, block)
-			if err != nil {
-				return nil, err
-			}
+	if !target.isIterable() {
+		return nil, newsberr(n, "non-iterable loop target")
+	}
 
-			if _, ok := pr.(*prReturn); ok {
-				return pr, nil
-			}
+	iter, ierr := target.iterator()
+	if ierr != nil {
+		return nil, sberrof(n, ierr)
+	}
 
-			if _, ok := pr.(*prBreak); ok {
-				// when break, exit loop itself
-				return nil, nil
+	for iter.hasnext() {
+		next, i := iter.next()
+		if n.cnt != nil {
+			env.defobj(mod, n.cnt.(*ndIdent).ident, &oI64{val: int64(i)})
+		}
+		env.defobj(mod, n.elem.(*ndIdent).ident, next)
+
+		pr, brk, err := runloopbody(env, mod, n.label, n.blocks)
+		if brk || err != nil {
+			return pr, err
+		}
+	}
+
+	return nil, nil
+}
+
+func procCondLoop(env *environment, mod *module, n *ndCondLoop) (procResult, shibaErr) {
+	env.createblockscope(mod)
+	defer env.delblockscope(mod)
+
+	for {
+		// condition is evaluated on every iteration. no condition means infinite loop.
+		if n.cond != nil {
+			cond, err := procAsObj(env, mod, n.cond)
+			if err != nil {
+				return nil, err
 			}
 
-			if _, ok := pr.(*prContinue); ok {
-				// when continue, exit running the block
+			if !cond.isTruethy() {
 				break
 			}
 		}
+
+		pr, brk, err := runloopbody(env, mod, n.label, n.blocks)
+		if brk || err != nil {
+			return pr, err
+		}
 	}
 
 	return nil, nil
 }
 
-func procStructDef(mod *module, n *ndStructDef) (procResult, shibaErr) {
+// runloopbody runs the loop body once. brk reports the loop must be exited, then pr is returned from the loop.
+// break and continue with the label of an outer loop are returned as is to be handled by the outer loop.
+func runloopbody(env *environment, mod *module, label string, blocks []node) (pr procResult, brk bool, err shibaErr) {
+	pr, err = runblock(env, mod, blocks)
+	if err != nil {
+		return nil, true, err
+	}
+
+	switch p := pr.(type) {
+	case *prReturn:
+		return pr, true, nil
+
+	case *prBreak:
+		if p.label != "" && p.label != label {
+			return pr, true, nil
+		}
+		return nil, true, nil
+
+	case *prContinue:
+		if p.label != "" && p.label != label {
+			return pr, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+func procStructDef(env *environment, mod *module, n *ndStructDef) (procResult, shibaErr) {
 	if _, ok := n.name.(*ndIdent); !ok {
 		return nil, newsberr(n, "invalid struct name %s", n.name)
 	}
 
 	name := n.name.(*ndIdent).ident
 	sd := &structdef{name: name}
+	sd.call = func(fn obj, args []obj) (obj, shibaErr) {
+		return callobj(env, n, fn, args, nil)
+	}
 
 	for _, v := range n.vars {
 		if _, ok := v.(*ndIdent); !ok {
 			return nil, newsberr(n, "invalid variable name %s in struct %s", v, name)
 		}
-		sd.vars = append(sd.vars, v.(*ndIdent).ident)
+
+		vname := v.(*ndIdent).ident
+		sd.vars = append(sd.vars, vname)
+
+		// the variable named after the struct defined before is the embedded struct
+		if esd, ok := env.getstruct(mod, vname); ok {
+			sd.embeds = append(sd.embeds, esd)
+		}
 	}
 
 	for _, fn := range n.fns {
 		nfn := fn.(*ndFunDef)
-		params := []string{}
-		for _, p := range nfn.params {
-			i, ok := p.(*ndIdent)
-			if !ok {
-				return nil, newsberr(n, "function param %s must be identifier", p)
-			}
-			params = append(params, i.ident)
+		params, err := procParams(env, mod, nfn)
+		if err != nil {
+			return nil, err
 		}
 
-		f := &obj{
-			typ:    tMethod,
-			fmod:   mod,
-			name:   nfn.name,
-			params: params,
-			body:   nfn.blocks,
+		f := &oMethod{
+			mod:      mod,
+			name:     nfn.name,
+			params:   params,
+			body:     nfn.blocks,
+			recvname: "self",
+		}
+		if nfn.recv != "" {
+			f.recvname = nfn.recv
 		}
 		sd.defs = append(sd.defs, f)
 	}
@@ -445,24 +826,19 @@ func procStructDef(mod *module, n *ndStructDef) (procResult, shibaErr) {
 	return nil, nil
 }
 
-func procStructInit(mod *module, n *ndStructInit) (procResult, shibaErr) {
+func procStructInit(env *environment, mod *module, n *ndStructInit) (procResult, shibaErr) {
 	if _, ok := n.name.(*ndIdent); !ok {
 		return nil, newsberr(n, "invalid struct name %s", n.name)
 	}
 
 	name := n.name.(*ndIdent).ident
-	o := &obj{typ: tStruct, name: name, fields: map[string]*obj{}}
 
 	sd, ok := env.getstruct(mod, name)
 	if !ok {
 		return nil, newsberr(n, "struct %s is not defined", name)
 	}
 
-	for _, dsd := range sd.defs {
-		d := dsd.clone()
-		d.receiver = o
-		o.fields[d.name] = d
-	}
+	o := sd.newstruct()
 
 	d, ok := n.values.(*ndDict)
 	if !ok {
@@ -479,78 +855,239 @@ func procStructInit(mod *module, n *ndStructInit) (procResult, shibaErr) {
 			return nil, newsberr(n, "struct %s does not have field %s", name, k)
 		}
 
-		v, err := procAsObj(mod, d.vals[i])
+		v, err := procAsObj(env, mod, d.vals[i])
 		if err != nil {
 			return nil, err
 		}
 
+		for _, e := range sd.embeds {
+			if vs, ok := v.(*oStruct); e.name == k && (!ok || vs.def != e) {
+				return nil, newsberr(d.vals[i], "embedded %s must be %s but got %s", k, e.name, v)
+			}
+		}
+
 		o.fields[k] = v
 	}
 
 	return &prObj{o: o}, nil
 }
 
-func procFunDef(mod *module, n *ndFunDef) (procResult, shibaErr) {
-	params := []string{}
+// procParams evaluates the default values of the function params.
+func procParams(env *environment, mod *module, n *ndFunDef) ([]*param, shibaErr) {
+	params := []*param{}
 	for _, p := range n.params {
-		i, ok := p.(*ndIdent)
+		np, ok := p.(*ndParam)
 		if !ok {
 			return nil, newsberr(n, "function param %s must be identifier", p)
 		}
-		params = append(params, i.ident)
-	}
-
-	f := &obj{
-		typ:    tFunc,
-		fmod:   mod,
-		name:   n.name,
-		params: params,
-		body:   n.blocks,
-	}
-	env.setobj(mod, n.name, f)
-	return nil, nil
-}
 
-func procIndex(mod *module, n *ndIndex) (procResult, shibaErr) {
-	tgt, err := procAsObj(mod, n.target)
-	if err != nil {
-		return nil, err
-	}
+		prm := &param{name: np.name, variadic: np.variadic, kwvariadic: np.kwvariadic}
+		if np.annot != nil {
+			o, err := procAsObj(env, mod, np.annot)
+			if err != nil {
+				return nil, err
+			}
 
-	if tgt.typ == tDict {
-		return procDictIndex(mod, tgt, n)
+			iface, ok := o.(*oInterface)
+			if !ok {
+				return nil, newsberr(np.annot, "annotation of parameter %s must be interface but got %s", np.name, o.typename())
+			}
+			prm.iface = iface
+		}
+
+		if np.dflt != nil {
+			o, err := procAsObj(env, mod, np.dflt)
+			if err != nil {
+				return nil, err
+			}
+			prm.dflt = o
+		}
+
+		params = append(params, prm)
+	}
+
+	return params, nil
+}
+
+func procInterfaceDef(env *environment, mod *module, n *ndInterfaceDef) (procResult, shibaErr) {
+	name, ok := n.name.(*ndIdent)
+	if !ok {
+		return nil, newsberr(n, "invalid interface name %s", n.name)
+	}
+
+	iface := &oInterface{name: name.ident}
+	for _, fn := range n.fns {
+		nfn := fn.(*ndFunDef)
+		params, err := procParams(env, mod, nfn)
+		if err != nil {
+			return nil, err
+		}
+
+		iface.methods = append(iface.methods, &methodsig{name: nfn.name, params: params})
+	}
+
+	env.setobj(mod, name.ident, iface)
+	return nil, nil
+}
+
+func procEnumDef(env *environment, mod *module, n *ndEnumDef) (procResult, shibaErr) {
+	name, ok := n.name.(*ndIdent)
+	if !ok {
+		return nil, newsberr(n, "invalid enum name %s", n.name)
+	}
+
+	e := &oEnum{name: name.ident}
+	for _, nv := range n.variants {
+		if _, ok := e.variant(nv.name); ok {
+			return nil, newsberr(nv, "duplicate variant %s in enum %s", nv.name, e.name)
+		}
+
+		v := &oVariant{enum: e, name: nv.name}
+		names := map[string]bool{}
+		for _, f := range nv.fields {
+			if names[f] {
+				return nil, newsberr(nv, "duplicate field %s in variant %s", f, v)
+			}
+			names[f] = true
+			v.params = append(v.params, &param{name: f})
+		}
+
+		if len(v.params) == 0 {
+			v.unit = &oEnumValue{variant: v}
+		}
+
+		e.variants = append(e.variants, v)
 	}
 
-	idx, err := procAsObj(mod, n.idx)
+	env.setobj(mod, e.name, e)
+	return nil, nil
+}
+
+func procFunDef(env *environment, mod *module, n *ndFunDef) (procResult, shibaErr) {
+	if n.recv != "" {
+		return nil, newsberr(n, "receiver %s is allowed only in struct method", n.recv)
+	}
+
+	params, err := procParams(env, mod, n)
 	if err != nil {
 		return nil, err
 	}
 
-	if idx.typ != tI64 {
-		return nil, newTypeMismatchErr(n, tI64, idx.typ)
+	f := &oFunc{
+		mod:    mod,
+		name:   n.name,
+		params: params,
+		body:   n.blocks,
 	}
+	env.setobj(mod, n.name, f)
+	return nil, nil
+}
 
-	i := int(idx.ival)
+func procIndex(env *environment, mod *module, n *ndIndex) (procResult, shibaErr) {
+	tgt, err := procAsObj(env, mod, n.target)
+	if err != nil {
+		return nil, err
+	}
 
-	if !tgt.cansequence() {
+	if d, ok := tgt.(*oDict); ok {
+		return procDictIndex(env, mod, d, n)
+	}
+
+	if s, ok := tgt.(*oStruct); ok {
+		return procStructIndex(env, mod, s, n)
+	}
+
+	idx, err := procAsObj(env, mod, n.idx)
+	if err != nil {
+		return nil, err
+	}
+
+	oi, ok := idx.(*oI64)
+	if !ok {
+		return nil, newTypeMismatchErr(n, "i64", idx)
+	}
+
+	if !tgt.isSequencable() {
 		return nil, newsberr(n, "%s is not iterable", tgt)
 	}
 
 	seq := tgt.sequence()
-	if seq.size() <= i {
-		return nil, newsberr(n, "index out of range [%d] with length %d", i, seq.size())
+	i, ierr := normindex(int(oi.val), seq.size())
+	if ierr != nil {
+		return nil, newsberr(n, "%s", ierr)
 	}
 
 	return &prObj{o: seq.index(i)}, nil
 }
 
-func procDictIndex(mod *module, d *obj, n *ndIndex) (procResult, shibaErr) {
-	key, err := procAsObj(mod, n.idx)
+func procDel(env *environment, mod *module, n *ndDel) (procResult, shibaErr) {
+	for _, target := range n.targets {
+		if sl, ok := target.(*ndSlice); ok {
+			if err := procDelSlice(env, mod, sl); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		idx, ok := target.(*ndIndex)
+		if !ok {
+			return nil, newsberr(target, "cannot delete %s", target)
+		}
+
+		tgt, err := procAsObj(env, mod, idx.target)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := procAsObj(env, mod, idx.idx)
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tgt.(type) {
+		case *oDict:
+			found, derr := t.dict.del(key)
+			if derr != nil {
+				return nil, sberrof(idx.idx, derr)
+			}
+
+			if !found {
+				return nil, &errDictKeyNotFound{key: key, l: idx.token().loc}
+			}
+
+		case *oList:
+			i, ok := key.(*oI64)
+			if !ok {
+				return nil, newTypeMismatchErr(idx, "i64", key)
+			}
+
+			seq := t.sequence().(mutableSequence)
+			ni, nerr := normindex(int(i.val), seq.size())
+			if nerr != nil {
+				return nil, newsberr(idx, "%s", nerr)
+			}
+
+			seq.delindex(ni)
+
+		default:
+			return nil, newsberr(idx, "cannot delete from %s", tgt.typename())
+		}
+	}
+
+	return nil, nil
+}
+
+func procDictIndex(env *environment, mod *module, d *oDict, n *ndIndex) (procResult, shibaErr) {
+	key, err := procAsObj(env, mod, n.idx)
 	if err != nil {
 		return nil, err
 	}
 
-	o, ok := d.dict.get(key)
+	o, ok, gerr := d.dict.get(key)
+	if gerr != nil {
+		return nil, sberrof(n.idx, gerr)
+	}
+
 	if !ok {
 		return nil, &errDictKeyNotFound{key: key, l: n.token().loc}
 	}
@@ -558,165 +1095,409 @@ func procDictIndex(mod *module, d *obj, n *ndIndex) (procResult, shibaErr) {
 	return &prObj{o: o}, nil
 }
 
-func procSlice(mod *module, n *ndSlice) (procResult, shibaErr) {
-	start, err := procAsObj(mod, n.start)
+// procStructIndex calls Index method of the struct for "s[i]".
+func procStructIndex(env *environment, mod *module, s *oStruct, n *ndIndex) (procResult, shibaErr) {
+	idx, err := procAsObj(env, mod, n.idx)
 	if err != nil {
 		return nil, err
 	}
 
-	if start.typ != tI64 {
-		return nil, newTypeMismatchErr(n, tI64, start.typ)
+	o, ok, cerr := s.def.callmethod(s, "Index", idx)
+	if cerr != nil {
+		return nil, sberrof(n, cerr)
 	}
 
-	end, err := procAsObj(mod, n.end)
+	if !ok {
+		return nil, newsberr(n, "%s is not indexable (Index method is not defined)", s.def.name)
+	}
+
+	return &prObj{o: o}, nil
+}
+
+func procDelSlice(env *environment, mod *module, n *ndSlice) shibaErr {
+	tgt, err := procAsObj(env, mod, n.target)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if end.typ != tI64 {
-		return nil, newTypeMismatchErr(n, tI64, end.typ)
+	l, ok := tgt.(*oList)
+	if !ok {
+		return newsberr(n, "cannot delete from %s", tgt.typename())
 	}
 
-	target, err := procAsObj(mod, n.target)
+	seq := l.sequence().(mutableSequence)
+	start, end, step, err := procSliceIndices(env, mod, n, seq.size())
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if !target.cansequence() {
-		return nil, newsberr(n, "%s is not iterable", target)
-	}
+	seq.delslice(start, end, step)
+	return nil
+}
 
-	seq := target.sequence()
+// procSliceIndices evaluates start, end and step of the slice and normalizes them for the sequence of the size.
+func procSliceIndices(env *environment, mod *module, n *ndSlice, size int) (int, int, int, shibaErr) {
+	idx := make([]*int, 3)
+	for i, nd := range []node{n.start, n.end, n.step} {
+		if nd == nil {
+			continue
+		}
 
-	si := int(start.ival)
-	ei := int(end.ival)
-	l := seq.size()
+		o, err := procAsObj(env, mod, nd)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+
+		oi, ok := o.(*oI64)
+		if !ok {
+			return 0, 0, 0, newTypeMismatchErr(nd, "i64", o)
+		}
 
-	if ei < si || si < 0 || l < ei {
-		return nil, newsberr(n, "invalid slice indices [%d:%d]", si, ei)
+		v := int(oi.val)
+		idx[i] = &v
 	}
 
-	return &prObj{o: seq.slice(si, ei)}, nil
+	start, end, step, err := sliceindices(size, idx[0], idx[1], idx[2])
+	if err != nil {
+		return 0, 0, 0, newsberr(n, "%s", err)
+	}
+
+	return start, end, step, nil
 }
 
-func procSelector(mod *module, n *ndSelector) (procResult, shibaErr) {
-	if !n.target.isexported() {
-		return nil, newsberr(n, "%s is unexported", n.target)
+func procSlice(env *environment, mod *module, n *ndSlice) (procResult, shibaErr) {
+	target, err := procAsObj(env, mod, n.target)
+	if err != nil {
+		return nil, err
+	}
+
+	if !target.isSequencable() {
+		return nil, newsberr(n, "%s is not iterable", target)
 	}
 
-	selector, err := procAsObj(mod, n.selector)
+	seq := target.sequence()
+	start, end, step, err := procSliceIndices(env, mod, n, seq.size())
 	if err != nil {
 		return nil, err
 	}
 
-	if selector.typ == tMod {
-		target, err := procAsObj(selector.mod, n.target)
-		if err != nil {
-			return nil, err
+	return &prObj{o: seq.slice(start, end, step)}, nil
+}
+
+func procSelector(env *environment, mod *module, n *ndSelector) (procResult, shibaErr) {
+	selector, err := procAsObj(env, mod, n.selector)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := selector.(*oNil); ok && n.optional {
+		return &prObj{o: NIL}, nil
+	}
+
+	// methods of built-in types are not exported, but they are accessible
+	if methods := builtinmethods(selector); methods != nil {
+		name, ok := n.target.(*ndIdent)
+		if !ok {
+			return nil, newsberr(n, "%s must be an identifier", n.target)
 		}
 
-		if target != nil {
-			return &prObj{o: target}, nil
+		m, ok := getbuiltinmethod(selector, name.ident)
+		if !ok {
+			return nil, newsberr(n, "unknown method %s of %s", name.ident, selector.typename())
 		}
 
-		return nil, nil
+		return &prObj{o: m}, nil
 	}
 
-	if selector.typ == tStruct {
-		field, ok := n.target.(*ndIdent)
+	// the variants of enum and the payload of enum value are accessible regardless of the case
+	switch s := selector.(type) {
+	case *oEnum:
+		name, ok := n.target.(*ndIdent)
 		if !ok {
 			return nil, newsberr(n, "%s must be an identifier", n.target)
 		}
 
-		f, ok := selector.fields[field.ident]
+		v, ok := s.variant(name.ident)
 		if !ok {
-			return nil, newsberr(n, "unknown field name %s in %s", field.ident, selector)
+			return nil, newsberr(n, "unknown variant %s in enum %s", name.ident, s.name)
+		}
+
+		return &prObj{o: v.member()}, nil
+
+	case *oEnumValue:
+		name, ok := n.target.(*ndIdent)
+		if !ok {
+			return nil, newsberr(n, "%s must be an identifier", n.target)
+		}
+
+		f, ok := s.field(name.ident)
+		if !ok {
+			return nil, newsberr(n, "unknown field %s in %s", name.ident, s.variant)
 		}
 
 		return &prObj{o: f}, nil
 	}
 
-	return nil, newsberr(n, "selector %s is not a module or struct", selector)
-}
+	if !n.target.isexported() {
+		return nil, newsberr(n, "%s is unexported", n.target)
+	}
 
-func procFuncall(mod *module, n *ndFuncall) (procResult, shibaErr) {
-	args := []*obj{}
-	for _, a := range n.args {
-		o, err := procAsObj(mod, a)
+	switch s := selector.(type) {
+	case *oMod:
+		target, err := procAsObj(env, s.mod, n.target)
 		if err != nil {
 			return nil, err
 		}
 
-		args = append(args, o)
+		return &prObj{o: target}, nil
+
+	case *oStruct:
+		field, ok := n.target.(*ndIdent)
+		if !ok {
+			return nil, newsberr(n, "%s must be an identifier", n.target)
+		}
+
+		// the field or method can be promoted from the embedded struct
+		holder, err := s.lookup(field.ident)
+		if err != nil {
+			return nil, newsberr(n, "%s", err)
+		}
+
+		if holder != nil {
+			if f, ok := holder.fields[field.ident]; ok {
+				return &prObj{o: f}, nil
+			}
+
+			if m, ok := holder.def.getmethod(field.ident); ok {
+				return &prObj{o: m.bind(holder)}, nil
+			}
+		}
+
+		return nil, newsberr(n, "unknown field name %s in %s", field.ident, selector)
 	}
 
-	fn, err := procAsObj(mod, n.fn)
+	return nil, newsberr(n, "selector %s is not a module or struct", selector)
+}
+
+func procFuncall(env *environment, mod *module, n *ndFuncall) (procResult, shibaErr) {
+	args, kw, err := procArgs(env, mod, n.args)
 	if err != nil {
 		return nil, err
 	}
 
-	if fn.typ == tBuiltinFunc {
-		o, err := fn.bfnbody(args...)
-		if err != nil {
-			return nil, newsberr(n, err.Error())
-		}
-
-		return &prObj{o: o}, nil
+	fn, err := procAsObj(env, mod, n.fn)
+	if err != nil {
+		return nil, err
 	}
 
-	if fn.typ == tGoStdModFunc {
-		o, err := fn.gostdmodfunc(args...)
-		if err != nil {
-			return nil, newsberr(n, err.Error())
+	// a?.f() is nil when a is nil
+	if s, ok := n.fn.(*ndSelector); ok && s.optional {
+		if _, ok := fn.(*oNil); ok {
+			return &prObj{o: NIL}, nil
 		}
+	}
 
-		return &prObj{o: o}, nil
+	o, err := callobj(env, n, fn, args, kw)
+	if err != nil {
+		return nil, err
 	}
 
-	if fn.typ == tFunc || fn.typ == tMethod {
-		if len(fn.params) != len(args) {
-			return nil, newsberr(n, "argument mismatch on %s()", fn.name)
+	return &prObj{o: o}, nil
+}
+
+// procArgs evaluates the args of the function call into positional args and keyword args.
+// kwargs is nil if no keyword arg is given.
+func procArgs(env *environment, mod *module, nargs []node) ([]obj, *kwargs, shibaErr) {
+	args := []obj{}
+	var kw *kwargs
+	setkw := func(n node, name string, o obj) shibaErr {
+		if kw == nil {
+			kw = newkwargs()
 		}
 
-		env.createfuncscope(fn.fmod)
-		for i := range fn.params {
-			env.setobj(fn.fmod, fn.params[i], args[i].clone())
+		if err := kw.set(name, o); err != nil {
+			return newsberr(n, "%s", err)
 		}
+		return nil
+	}
 
-		if fn.typ == tMethod {
-			for k, v := range fn.receiver.fields {
-				env.setobj(fn.fmod, k, v)
+	for _, a := range nargs {
+		switch na := a.(type) {
+		case *ndKwArg:
+			o, err := procAsObj(env, mod, na.val)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if err := setkw(na, na.name, o); err != nil {
+				return nil, nil, err
 			}
-		}
 
-		for _, block := range fn.body {
-			pr, err := process(fn.fmod, block)
+		case *ndSpread:
+			o, err := procAsObj(env, mod, na.target)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 
-			if r, ok := pr.(*prReturn); ok {
-				env.delfuncscope(fn.fmod)
-				return &prObj{o: r.ret}, nil
+			if !na.kw {
+				vals, ok, ierr := elems(o)
+				if ierr != nil {
+					return nil, nil, sberrof(na, ierr)
+				}
+
+				if !ok {
+					return nil, nil, newsberr(na, "cannot spread %s as args", o.typename())
+				}
+				args = append(args, vals...)
+				continue
 			}
 
-			if _, ok := pr.(*prBreak); ok {
-				return nil, newsberr(n, "break in non-loop")
+			d, ok := o.(*oDict)
+			if !ok {
+				return nil, nil, newsberr(na, "cannot spread %s as keyword args", o.typename())
 			}
 
-			if _, ok := pr.(*prContinue); ok {
-				return nil, newsberr(n, "continue in non-loop")
+			for e := d.dict.entries.Front(); e != nil; e = e.Next() {
+				de := e.Value.(*dictentry)
+				ks, ok := de.k.(*oStr)
+				if !ok {
+					return nil, nil, newsberr(na, "keyword must be str but got %s", de.k.typename())
+				}
+
+				if err := setkw(na, ks.String(), de.v); err != nil {
+					return nil, nil, err
+				}
 			}
+
+		default:
+			o, err := procAsObj(env, mod, a)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			args = append(args, o)
+		}
+	}
+
+	return args, kw, nil
+}
+
+// callobj calls the callable obj. n is where the call happens, which is used as the location of errors.
+func callobj(env *environment, n node, fn obj, args []obj, kw *kwargs) (obj, shibaErr) {
+	switch f := fn.(type) {
+	case *oBuiltinFunc:
+		if err := kw.check(f.name, f.kwnames); err != nil {
+			return nil, newsberr(n, "%s", err)
+		}
+
+		// the builtin might call back a function via env.call()
+		prev := env.caller
+		env.caller = n
+		defer func() { env.caller = prev }()
+
+		o, err := f.body(env, kw, args...)
+		if err != nil {
+			// exit() and the error in the function called back by the builtin
+			// must be propagated as they are
+			if se, ok := err.(shibaErr); ok {
+				return nil, se
+			}
+
+			return nil, newsberr(n, "%s", err)
+		}
+
+		return o, nil
+
+	case *oType:
+		if f.conv == nil {
+			return nil, newsberr(n, "type %s is not callable", f)
+		}
+
+		if err := kw.check(f.name, nil); err != nil {
+			return nil, newsberr(n, "%s", err)
+		}
+
+		o, err := f.conv(args...)
+		if err != nil {
+			return nil, newsberr(n, "%s", err)
+		}
+
+		return o, nil
+
+	case *oGoStdModFunc:
+		if err := kw.check(f.name, f.kwnames); err != nil {
+			return nil, newsberr(n, "%s", err)
 		}
 
-		env.delfuncscope(fn.fmod)
-		return nil, nil
+		o, err := f.body(env, kw, args...)
+		if err != nil {
+			return nil, newsberr(n, "%s", err)
+		}
+
+		return o, nil
+
+	case *oFunc:
+		return callfunc(env, n, f.mod, f.name, f.params, f.body, nil, "", args, kw)
+
+	case *oVariant:
+		vals, err := bindargs(f.String(), f.params, args, kw)
+		if err != nil {
+			return nil, newsberr(n, "%s", err)
+		}
+
+		return &oEnumValue{variant: f, vals: vals}, nil
+
+	case *oMethod:
+		return callfunc(env, n, f.mod, f.name, f.params, f.body, f.receiver, f.recvname, args, kw)
 	}
 
-	return nil, newsberr(n, "cannot call %s", n.fn)
+	return nil, newsberr(n, "cannot call %s", fn.typename())
 }
 
-func procImport(mod *module, n *ndImport) (procResult, shibaErr) {
+// callfunc calls the user-defined function or method.
+// The args are copied, so the modification to a list, dict or struct arg is not visible to the caller.
+// If receiver is not nil, it is defined as recvname without copy, and its fields and methods are visible as variables
+// in the function body. Assigning to the field variable updates the receiver directly.
+func callfunc(env *environment, n node, fmod *module, name string, params []*param, body []node, receiver *oStruct, recvname string, args []obj, kw *kwargs) (obj, shibaErr) {
+	vals, err := bindargs(name, params, args, kw)
+	if err != nil {
+		return nil, newsberr(n, "%s", err)
+	}
+
+	env.createfuncscope(fmod, receiver)
+	defer env.delfuncscope(fmod)
+
+	if receiver != nil {
+		env.defobj(fmod, recvname, receiver)
+	}
+
+	for i := range params {
+		env.defobj(fmod, params[i].name, vals[i].clone())
+	}
+
+	for _, block := range body {
+		pr, err := process(env, fmod, block)
+		if err != nil {
+			return nil, err
+		}
+
+		if r, ok := pr.(*prReturn); ok {
+			return r.ret, nil
+		}
+
+		if _, ok := pr.(*prBreak); ok {
+			return nil, newsberr(n, "break in non-loop")
+		}
+
+		if _, ok := pr.(*prContinue); ok {
+			return nil, newsberr(n, "continue in non-loop")
+		}
+	}
+
+	return NIL, nil
+}
+
+func procImport(env *environment, mod *module, n *ndImport) (procResult, shibaErr) {
 	// first, try to import user-defined module
 	m, err := newmodule(filepath.Join(mod.directory, n.target))
 	if err != nil {
@@ -731,107 +1512,159 @@ func procImport(mod *module, n *ndImport) (procResult, shibaErr) {
 		}
 	}
 
-	if err := runmod(m); err != nil {
+	if err := runmod(env, m); err != nil {
 		return nil, err
 	}
 
-	env.setobj(mod, m.name, &obj{typ: tMod, mod: m})
+	env.setobj(mod, m.name, &oMod{mod: m})
 
 	return nil, nil
 }
 
-func procBinaryOp(mod *module, n *ndBinaryOp) (procResult, shibaErr) {
-	l, err := procAsObj(mod, n.left)
+func procBinaryOp(env *environment, mod *module, n *ndBinaryOp) (procResult, shibaErr) {
+	l, err := procAsObj(env, mod, n.left)
 	if err != nil {
 		return nil, err
 	}
 
-	r, err := procAsObj(mod, n.right)
+	// right side of "??" is evaluated only when it is needed
+	if _, ok := l.(*oNil); !ok && n.op == boCoalesce {
+		return &prObj{o: l}, nil
+	}
+
+	r, err := procAsObj(env, mod, n.right)
 	if err != nil {
 		return nil, err
 	}
 
 	o, err2 := computeBinaryOp(l, r, n.op)
 	if err2 != nil {
-		return nil, newsberr(n, err2.Error())
+		return nil, sberrof(n, err2)
 	}
 
 	return &prObj{o: o}, nil
 }
 
-func procUnaryOp(mod *module, n *ndUnaryOp) (procResult, shibaErr) {
-	o, err := procAsObj(mod, n.target)
+func procUnaryOp(env *environment, mod *module, n *ndUnaryOp) (procResult, shibaErr) {
+	o, err := procAsObj(env, mod, n.target)
 	if err != nil {
 		return nil, err
 	}
 
-	switch n.op {
-	case uoPlus:
-		if o.typ == tI64 || o.typ == tF64 {
-			return &prObj{o: o}, nil
-		}
+	if r := o.unaryop(n.op); r != nil {
+		return &prObj{o: r}, nil
+	}
 
-	case uoMinus:
-		if o.typ == tI64 {
-			return &prObj{o: &obj{typ: tI64, ival: -o.ival}}, nil
-		}
+	return nil, newsberr(n, "invalid operation [%s]%s", n.op, n.target)
+}
 
-		if o.typ == tF64 {
-			return &prObj{o: &obj{typ: tF64, fval: -o.fval}}, nil
+func procList(env *environment, mod *module, n *ndList) (procResult, shibaErr) {
+	l := &oList{}
+	for _, val := range n.vals {
+		o, err := procAsObj(env, mod, val)
+		if err != nil {
+			return nil, err
 		}
+		l.vals = append(l.vals, o)
+	}
 
-	case uoLogicalNot:
-		if o.typ == tBool {
-			return &prObj{o: &obj{typ: tBool, bval: !o.bval}}, nil
-		}
+	return &prObj{o: l}, nil
+}
 
-	case uoBitwiseNot:
-		if o.typ == tI64 {
-			return &prObj{o: &obj{typ: tI64, ival: ^o.ival}}, nil
+func procTuple(env *environment, mod *module, n *ndTuple) (procResult, shibaErr) {
+	t := &oTuple{vals: make([]obj, len(n.vals))}
+	for i, val := range n.vals {
+		o, err := procAsObj(env, mod, val)
+		if err != nil {
+			return nil, err
 		}
+		t.vals[i] = o
 	}
 
-	return nil, newsberr(n, "invalid operation [%s]%s", n.op, n.target)
+	return &prObj{o: t}, nil
 }
 
-func procList(mod *module, n *ndList) (procResult, shibaErr) {
-	l := &obj{typ: tList}
+func procSet(env *environment, mod *module, n *ndSet) (procResult, shibaErr) {
+	s := &oSet{dict: newdict()}
 	for _, val := range n.vals {
-		o, err := procAsObj(mod, val)
+		o, err := procAsObj(env, mod, val)
 		if err != nil {
 			return nil, err
 		}
-		l.list = append(l.list, o)
+
+		if err := s.add(o); err != nil {
+			return nil, sberrof(val, err)
+		}
 	}
 
-	return &prObj{o: l}, nil
+	return &prObj{o: s}, nil
 }
 
-func procDict(mod *module, n *ndDict) (procResult, shibaErr) {
-	d := &obj{typ: tDict, dict: newdict()}
+func procDict(env *environment, mod *module, n *ndDict) (procResult, shibaErr) {
+	d := &oDict{dict: newdict()}
 	for i := range n.keys {
-		key, err := procAsObj(mod, n.keys[i])
+		key, err := procAsObj(env, mod, n.keys[i])
 		if err != nil {
 			return nil, err
 		}
 
-		val, err := procAsObj(mod, n.vals[i])
+		val, err := procAsObj(env, mod, n.vals[i])
 		if err != nil {
 			return nil, err
 		}
 
-		d.dict.set(key, val)
+		if err := d.dict.set(key, val); err != nil {
+			return nil, sberrof(n.keys[i], err)
+		}
 	}
 
 	return &prObj{o: d}, nil
 }
 
-func procIdent(mod *module, n *ndIdent) (procResult, shibaErr) {
+func procFStr(env *environment, mod *module, n *ndFStr) (procResult, shibaErr) {
+	var sb strings.Builder
+	for _, part := range n.parts {
+		o, err := procAsObj(env, mod, part)
+		if err != nil {
+			return nil, err
+		}
+
+		str, serr := strof(o)
+		if serr != nil {
+			return nil, sberrof(part, serr)
+		}
+
+		sb.WriteString(str)
+	}
+
+	return &prObj{o: newstr(sb.String())}, nil
+}
+
+func procFormat(env *environment, mod *module, n *ndFormat) (procResult, shibaErr) {
+	o, err := procAsObj(env, mod, n.target)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err2 := formatobj(o, n.spec)
+	if err2 != nil {
+		return nil, newsberr(n, "%s", err2)
+	}
+
+	return &prObj{o: newstr(s)}, nil
+}
+
+func procIdent(env *environment, mod *module, n *ndIdent) (procResult, shibaErr) {
 	o, ok := env.getobj(mod, n.ident)
 	if ok {
 		return &prObj{o: o}, nil
 	}
 
+	// struct name is evaluated as the type
+	if sd, ok := env.getstruct(mod, n.ident); ok {
+		return &prObj{o: &oType{name: sd.name, def: sd}}, nil
+	}
+
 	bf, ok := builtinFns[n.ident]
 	if ok {
 		return &prObj{o: bf}, nil
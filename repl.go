@@ -21,7 +21,7 @@ func termprintln(t *term.Terminal, msg string) {
 // 1. Read a line.
 // 2. Try parsing the line. If parse fails, try to read next line and combines them until succeeds.
 // 3. Process the line.
-func repl() int {
+func repl(env *environment) int {
 	mod := newreplmodule()
 	env.register(newreplmodule())
 
@@ -33,7 +33,8 @@ func repl() int {
 
 	t := term.NewTerminal(os.Stdin, prompt)
 	defer term.Restore(int(os.Stdin.Fd()), origState)
-	printer = t
+	env.stdout = t
+	env.stderr = t
 
 	cur := ""
 	for {
@@ -68,8 +69,14 @@ func repl() int {
 			continue // do not reset cur to combine upcoming line and retry parse
 		}
 
-		pr, err := process(mod, stmt)
+		newchecker(env, mod).check(stmt)
+
+		pr, err := process(env, mod, stmt)
 		if err != nil {
+			if ee, ok := err.(*errExit); ok {
+				return ee.code
+			}
+
 			termprintln(t, err.Error())
 			cur = ""
 			t.SetPrompt(prompt)
@@ -79,7 +86,7 @@ func repl() int {
 		if pr != nil {
 			switch result := pr.(type) {
 			case *prObj:
-				if result.o.typ != tNil {
+				if _, ok := result.o.(*oNil); !ok {
 					termprintln(t, result.o.String())
 				}
 
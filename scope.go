@@ -3,14 +3,17 @@ package main
 import "container/list"
 
 type scope struct {
-	objs        map[string]*obj
+	objs        map[string]obj
 	structdefs  map[string]*structdef
 	blockscopes *list.List
+	// recv is the receiver if the scope is of a method.
+	// Its fields and methods are visible after the local variables, and the fields are updated directly.
+	recv *oStruct
 }
 
 func newscope() *scope {
 	return &scope{
-		objs:        map[string]*obj{},
+		objs:        map[string]obj{},
 		structdefs:  map[string]*structdef{},
 		blockscopes: list.New(),
 	}
@@ -41,12 +44,11 @@ func (s *scope) setstruct(name string, sd *structdef) {
 	s.blockscopes.Back().Value.(*blockscope).structdefs[name] = sd
 }
 
-func (s *scope) setobj(name string, o *obj) {
-	if s.blockscopes.Len() == 0 {
-		s.objs[name] = o
-		return
-	}
-
+// setobj assigns the obj to the name.
+// If the name is already defined in the scope, the definition is updated.
+// If the name is a field of the receiver, the field is updated.
+// If not, the name is newly defined in the innermost block.
+func (s *scope) setobj(name string, o obj) {
 	for e := s.blockscopes.Back(); e != nil; e = e.Prev() {
 		bs := e.Value.(*blockscope)
 		if _, ok := bs.objs[name]; ok {
@@ -55,9 +57,38 @@ func (s *scope) setobj(name string, o *obj) {
 		}
 	}
 
+	if _, ok := s.objs[name]; ok {
+		s.objs[name] = o
+		return
+	}
+
+	if s.recv != nil && s.recv.setfield(name, o) {
+		return
+	}
+
+	if s.blockscopes.Len() == 0 {
+		s.objs[name] = o
+		return
+	}
+
 	s.blockscopes.Back().Value.(*blockscope).objs[name] = o
 }
 
+// defobj defines the name in the innermost block regardless of the outer definition.
+func (s *scope) defobj(name string, o obj) {
+	if s.blockscopes.Len() == 0 {
+		s.objs[name] = o
+		return
+	}
+
+	s.blockscopes.Back().Value.(*blockscope).objs[name] = o
+}
+
+func (s *scope) hasobj(name string) bool {
+	_, ok := s.getobj(name)
+	return ok
+}
+
 func (s *scope) getstruct(name string) (*structdef, bool) {
 	for e := s.blockscopes.Back(); e != nil; e = e.Prev() {
 		bs := e.Value.(*blockscope)
@@ -70,7 +101,7 @@ func (s *scope) getstruct(name string) (*structdef, bool) {
 	return sd, ok
 }
 
-func (s *scope) getobj(name string) (*obj, bool) {
+func (s *scope) getobj(name string) (obj, bool) {
 	for e := s.blockscopes.Back(); e != nil; e = e.Prev() {
 		bs := e.Value.(*blockscope)
 		if o, ok := bs.objs[name]; ok {
@@ -78,8 +109,15 @@ func (s *scope) getobj(name string) (*obj, bool) {
 		}
 	}
 
-	o, ok := s.objs[name]
-	return o, ok
+	if o, ok := s.objs[name]; ok {
+		return o, ok
+	}
+
+	if s.recv != nil {
+		return s.recv.member(name)
+	}
+
+	return nil, false
 }
 
 func (s *scope) getglobstruct(name string) (*structdef, bool) {
@@ -87,19 +125,19 @@ func (s *scope) getglobstruct(name string) (*structdef, bool) {
 	return sd, ok
 }
 
-func (s *scope) getglobobj(name string) (*obj, bool) {
+func (s *scope) getglobobj(name string) (obj, bool) {
 	o, ok := s.objs[name]
 	return o, ok
 }
 
 type blockscope struct {
-	objs       map[string]*obj
+	objs       map[string]obj
 	structdefs map[string]*structdef
 }
 
 func newblockscope() *blockscope {
 	return &blockscope{
-		objs:       map[string]*obj{},
+		objs:       map[string]obj{},
 		structdefs: map[string]*structdef{},
 	}
 }
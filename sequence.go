@@ -1,11 +1,118 @@
 package main
 
+import "fmt"
+
 // sequence is an object which contains multiple values,
 // which have explicit order, can be accessed by index.
+// The indices given to the methods must be normalized by normindex() or sliceindices() beforehand.
 type sequence interface {
 	size() int
-	index(idx int) *obj
-	slice(start, end int) *obj
+	index(idx int) obj
+	// slice returns the elements from start to end (exclusive) stepping by step.
+	slice(start, end, step int) obj
+}
+
+// mutableSequence is a sequence whose elements can be replaced and deleted.
+type mutableSequence interface {
+	sequence
+	setindex(idx int, o obj)
+	delindex(idx int)
+	setslice(start, end, step int, vals []obj) error
+	delslice(start, end, step int)
+}
+
+// normindex converts the index which can be negative into the index from the beginning.
+// -1 is the last element.
+func normindex(idx, size int) (int, error) {
+	i := idx
+	if i < 0 {
+		i += size
+	}
+
+	if i < 0 || size <= i {
+		return 0, fmt.Errorf("index out of range [%d] with length %d", idx, size)
+	}
+
+	return i, nil
+}
+
+// sliceindices computes the actual indices of [start:end:step] in Python's manner.
+// nil means the index is omitted. Negative indices count from the end,
+// and the indices out of range are clipped to the range.
+func sliceindices(size int, start, end, step *int) (int, int, int, error) {
+	st := 1
+	if step != nil {
+		st = *step
+	}
+
+	if st == 0 {
+		return 0, 0, 0, fmt.Errorf("slice step cannot be zero")
+	}
+
+	// adjust converts the index which can be negative or out of range into [lower, upper].
+	adjust := func(idx *int, dflt, lower, upper int) int {
+		if idx == nil {
+			return dflt
+		}
+
+		i := *idx
+		if i < 0 {
+			i += size
+		}
+
+		if i < lower {
+			return lower
+		}
+
+		if i > upper {
+			return upper
+		}
+
+		return i
+	}
+
+	if st > 0 {
+		return adjust(start, 0, 0, size), adjust(end, size, 0, size), st, nil
+	}
+
+	// when step is negative, the default start is the last element and the default end is before the first.
+	return adjust(start, size-1, -1, size-1), adjust(end, -1, -1, size-1), st, nil
+}
+
+// maxrepeat is the maximum length of a str, list or tuple built by repetition.
+const maxrepeat = 1 << 30
+
+// repeatlen returns the length of size elements repeated n times.
+// Negative n is treated as 0. It fails when the length overflows or exceeds maxrepeat.
+func repeatlen(size, n int) (int, error) {
+	if n <= 0 || size == 0 {
+		return 0, nil
+	}
+
+	if size > maxrepeat/n {
+		return 0, fmt.Errorf("repeated length is too large")
+	}
+
+	return size * n, nil
+}
+
+// sliceidx returns the indices selected by the normalized [start:end:step].
+// The number of indices is computed up front so that a huge step cannot overflow the index.
+func sliceidx(start, end, step int) []int {
+	n := 0
+	if step > 0 && start < end {
+		n = (end-start-1)/step + 1
+	} else if step < 0 && start > end {
+		// division truncates toward zero, so this is ceil((start-end)/-step) without negating step.
+		n = 1 - (start-end-1)/step
+	}
+
+	idx := make([]int, n)
+	for k := range idx {
+		idx[k] = start + k*step
+	}
+
+	return idx
 }
 
 type strSequence struct {
@@ -16,31 +123,113 @@ func (s *strSequence) size() int {
 	return len(s.runes)
 }
 
-func (s *strSequence) index(idx int) *obj {
-	return &obj{typ: tStr, bytes: []byte(string(s.runes[idx]))}
+func (s *strSequence) index(idx int) obj {
+	return newstr(string(s.runes[idx]))
 }
 
-func (s *strSequence) slice(start, end int) *obj {
-	rs := s.runes[start:end]
-	var ret string
-	for _, r := range rs {
-		ret += string(r)
+func (s *strSequence) slice(start, end, step int) obj {
+	if step == 1 {
+		if end < start {
+			return newstr("")
+		}
+		return newstr(string(s.runes[start:end]))
+	}
+
+	runes := []rune{}
+	for _, i := range sliceidx(start, end, step) {
+		runes = append(runes, s.runes[i])
 	}
-	return &obj{typ: tStr, bytes: []byte(ret)}
+	return newstr(string(runes))
 }
 
-type listSequence struct {
-	vals []*obj
+type tupleSequence struct {
+	vals []obj
 }
 
-func (s *listSequence) size() int {
+func (s *tupleSequence) size() int {
 	return len(s.vals)
 }
 
-func (s *listSequence) index(idx int) *obj {
+func (s *tupleSequence) index(idx int) obj {
 	return s.vals[idx]
 }
 
-func (s *listSequence) slice(start, end int) *obj {
-	return &obj{typ: tList, list: s.vals[start:end]}
+func (s *tupleSequence) slice(start, end, step int) obj {
+	vals := []obj{}
+	for _, i := range sliceidx(start, end, step) {
+		vals = append(vals, s.vals[i])
+	}
+	return &oTuple{vals: vals}
+}
+
+// listSequence refers the list itself so that the modification is visible in the list.
+type listSequence struct {
+	l *oList
+}
+
+func (s *listSequence) size() int {
+	return len(s.l.vals)
+}
+
+func (s *listSequence) index(idx int) obj {
+	return s.l.vals[idx]
+}
+
+func (s *listSequence) slice(start, end, step int) obj {
+	vals := []obj{}
+	for _, i := range sliceidx(start, end, step) {
+		vals = append(vals, s.l.vals[i])
+	}
+	return &oList{vals: vals}
+}
+
+func (s *listSequence) setindex(idx int, o obj) {
+	s.l.vals[idx] = o
+}
+
+func (s *listSequence) delindex(idx int) {
+	s.l.vals = append(s.l.vals[:idx], s.l.vals[idx+1:]...)
+}
+
+// setslice replaces the elements in the slice with vals.
+// When step is 1, the number of vals can differ from the slice so the list grows or shrinks.
+// Otherwise, they must be the same.
+func (s *listSequence) setslice(start, end, step int, vals []obj) error {
+	if step == 1 {
+		if end < start {
+			end = start
+		}
+
+		newvals := make([]obj, 0, len(s.l.vals)-(end-start)+len(vals))
+		newvals = append(newvals, s.l.vals[:start]...)
+		newvals = append(newvals, vals...)
+		newvals = append(newvals, s.l.vals[end:]...)
+		s.l.vals = newvals
+		return nil
+	}
+
+	idx := sliceidx(start, end, step)
+	if len(idx) != len(vals) {
+		return fmt.Errorf("cannot assign %d values to extended slice of size %d", len(vals), len(idx))
+	}
+
+	for i, j := range idx {
+		s.l.vals[j] = vals[i]
+	}
+	return nil
+}
+
+func (s *listSequence) delslice(start, end, step int) {
+	del := map[int]bool{}
+	for _, i := range sliceidx(start, end, step) {
+		del[i] = true
+	}
+
+	vals := make([]obj, 0, len(s.l.vals)-len(del))
+	for i, v := range s.l.vals {
+		if !del[i] {
+			vals = append(vals, v)
+		}
+	}
+	s.l.vals = vals
 }
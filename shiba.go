@@ -1,26 +1,28 @@
 package main
 
 import (
-	"os"
+	"fmt"
 )
 
 // target is a filename such as xxx/yyy.sb
-func interpret(target string) int {
-	printer = os.Stdout
-
+func interpret(env *environment, target string) int {
 	modname := filetomod(target)
 	mod, err := newmodule(modname)
 	if err != nil {
-		werr("cannot load module %s: %s", modname, err)
+		fmt.Fprintf(env.stderr, "cannot load module %s: %s\n", modname, err)
 		return 1
 	}
 
-	if err := runmod(mod); err != nil {
+	if err := runmod(env, mod); err != nil {
+		if ee, ok := err.(*errExit); ok {
+			return ee.code
+		}
+
 		loc := err.loc()
 		if loc != nil {
-			werr("%s:%d:%d %s", loc.mod, loc.line, loc.col, err)
+			fmt.Fprintf(env.stderr, "%s:%d:%d %s\n", loc.mod, loc.line, loc.col, err)
 		} else {
-			werr("%s", err)
+			fmt.Fprintf(env.stderr, "%s\n", err)
 		}
 		return 1
 	}
@@ -28,10 +30,11 @@ func interpret(target string) int {
 	return 0
 }
 
-func runmod(mod *module) shibaErr {
+func runmod(env *environment, mod *module) shibaErr {
 	env.register(mod)
 
 	p := newparser(mod)
+	c := newchecker(env, mod)
 	for {
 		stmt, err := p.parsestmt()
 		if err != nil {
@@ -42,7 +45,9 @@ func runmod(mod *module) shibaErr {
 			break
 		}
 
-		pr, err := process(mod, stmt)
+		c.check(stmt)
+
+		pr, err := process(env, mod, stmt)
 		if err != nil {
 			return err
 		}
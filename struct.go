@@ -1,9 +1,28 @@
 package main
 
+import (
+	"fmt"
+	"strings"
+)
+
 type structdef struct {
 	name string
 	vars []string
-	defs []*obj
+	defs []*oMethod
+
+	// embeds are the embedded structs in the defined order.
+	// Each of them is also a field in vars named after the struct.
+	embeds []*structdef
+
+	// call calls the method in the environment where the struct is defined.
+	// It is used by the operations such as dict lookup which consult the methods.
+	// It is a func value not to make initialization cycle with the built-in functions.
+	call func(fn obj, args []obj) (obj, shibaErr)
+}
+
+// String returns the name and the fields.
+func (sd *structdef) String() string {
+	return sd.name + "{" + strings.Join(sd.vars, ", ") + "}"
 }
 
 func (sd *structdef) hasfield(f string) bool {
@@ -15,3 +34,252 @@ func (sd *structdef) hasfield(f string) bool {
 
 	return false
 }
+
+func (sd *structdef) getmethod(name string) (*oMethod, bool) {
+	for _, d := range sd.defs {
+		if d.name == name {
+			return d, true
+		}
+	}
+
+	return nil, false
+}
+
+// declares reports if the struct itself has the field or method.
+func (sd *structdef) declares(name string) bool {
+	if sd.hasfield(name) {
+		return true
+	}
+
+	_, ok := sd.getmethod(name)
+	return ok
+}
+
+// resolve finds the struct which declares the field or method in Go's manner,
+// then returns the path of the embedded field names to reach it.
+// The own fields and methods shadow the promoted ones, and the shallower embedded ones shadow the deeper.
+// It fails if multiple embedded structs at the same depth declare the name.
+func (sd *structdef) resolve(name string) ([]string, bool, error) {
+	type candidate struct {
+		def  *structdef
+		path []string
+	}
+
+	level := []candidate{{def: sd}}
+	for len(level) > 0 {
+		found := []candidate{}
+		next := []candidate{}
+		for _, c := range level {
+			if c.def.declares(name) {
+				found = append(found, c)
+			}
+
+			for _, e := range c.def.embeds {
+				path := append(append([]string{}, c.path...), e.name)
+				next = append(next, candidate{def: e, path: path})
+			}
+		}
+
+		switch len(found) {
+		case 0:
+			level = next
+		case 1:
+			return found[0].path, true, nil
+		default:
+			return nil, false, fmt.Errorf("ambiguous selector %s in %s", name, sd.name)
+		}
+	}
+
+	return nil, false, nil
+}
+
+// findmethod returns the method of the struct including the promoted one.
+func (sd *structdef) findmethod(name string) (*oMethod, bool) {
+	path, ok, err := sd.resolve(name)
+	if err != nil || !ok {
+		return nil, false
+	}
+
+	def := sd
+	for _, ename := range path {
+		for _, e := range def.embeds {
+			if e.name == ename {
+				def = e
+				break
+			}
+		}
+	}
+
+	return def.getmethod(name)
+}
+
+// newstruct returns the struct whose embedded structs are initialized with the empty ones.
+func (sd *structdef) newstruct() *oStruct {
+	s := &oStruct{def: sd, fields: map[string]obj{}}
+	for _, e := range sd.embeds {
+		s.fields[e.name] = e.newstruct()
+	}
+	return s
+}
+
+// callmethod calls the method of the struct including the promoted one.
+// ok is false if the struct does not have the method.
+func (sd *structdef) callmethod(s *oStruct, name string, args ...obj) (obj, bool, error) {
+	holder, err := s.lookup(name)
+	if err != nil || holder == nil {
+		return nil, false, err
+	}
+
+	m, ok := holder.def.getmethod(name)
+	if !ok {
+		return nil, false, nil
+	}
+
+	o, serr := sd.call(m.bind(holder), args)
+	if serr != nil {
+		return nil, true, serr
+	}
+
+	return o, true, nil
+}
+
+// hash calls Hash method of the struct to use it as a dict key.
+// The struct without Hash method is not hashable because it is mutable.
+func (sd *structdef) hash(s *oStruct) (uint64, error) {
+	o, ok, err := sd.callmethod(s, "Hash")
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		return 0, fmt.Errorf("unhashable type: %s (Hash method is not defined)", sd.name)
+	}
+
+	i, ok := o.(*oI64)
+	if !ok {
+		return 0, fmt.Errorf("%s.Hash() must return i64 but got %s", sd.name, o.typename())
+	}
+
+	return hashuint(sd.name, uint64(i.val)), nil
+}
+
+// eq calls Eq method of the struct for "==", "in" and dict keys. If Eq is not defined, the fields are compared.
+func (sd *structdef) eq(s *oStruct, x obj) (bool, error) {
+	o, ok, err := sd.callmethod(s, "Eq", x)
+	if err != nil {
+		return false, err
+	}
+
+	if !ok {
+		return s.fieldsequal(x)
+	}
+
+	return o.isTruethy(), nil
+}
+
+// opmethods are the methods called for the arithmetic operators.
+var opmethods = map[binaryOp]string{
+	boAdd: "Add",
+	boSub: "Sub",
+	boMul: "Mul",
+	boDiv: "Div",
+	boMod: "Mod",
+}
+
+// binaryop calls the method for "s op x". The comparisons are derived from Less and Eq.
+// nil is returned if the struct does not have the method.
+func (sd *structdef) binaryop(s *oStruct, op binaryOp, x obj) (obj, error) {
+	if name, ok := opmethods[op]; ok {
+		o, _, err := sd.callmethod(s, name, x)
+		return o, err
+	}
+
+	switch op {
+	case boLess, boLessEq, boGreater, boGreaterEq:
+		o, ok, err := sd.callmethod(s, "Less", x)
+		if err != nil || !ok {
+			return nil, err
+		}
+
+		less := o.isTruethy()
+		switch op {
+		case boLess:
+			return newbool(less), nil
+		case boGreaterEq:
+			return newbool(!less), nil
+		}
+
+		eq, err := sd.eq(s, x)
+		if err != nil {
+			return nil, err
+		}
+
+		if op == boLessEq {
+			return newbool(less || eq), nil
+		}
+		return newbool(!less && !eq), nil
+	}
+
+	return nil, nil
+}
+
+// len calls Len method for len(s), which must return i64.
+func (sd *structdef) len(s *oStruct) (int, bool, error) {
+	o, ok, err := sd.callmethod(s, "Len")
+	if err != nil || !ok {
+		return 0, ok, err
+	}
+
+	i, ok := o.(*oI64)
+	if !ok {
+		return 0, true, fmt.Errorf("%s.Len() must return i64 but got %s", sd.name, o.typename())
+	}
+
+	return int(i.val), true, nil
+}
+
+// iter calls Iter method for "for ... in s", which must return an iterable.
+func (sd *structdef) iter(s *oStruct) (obj, bool, error) {
+	o, ok, err := sd.callmethod(s, "Iter")
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+
+	if !o.isIterable() {
+		return nil, true, fmt.Errorf("%s.Iter() must return iterable but got %s", sd.name, o.typename())
+	}
+
+	return o, true, nil
+}
+
+// str calls String method to print the struct, which must return str.
+func (sd *structdef) str(s *oStruct) (string, bool, error) {
+	o, ok, err := sd.callmethod(s, "String")
+	if err != nil || !ok {
+		return "", ok, err
+	}
+
+	str, ok := o.(*oStr)
+	if !ok {
+		return "", true, fmt.Errorf("%s.String() must return str but got %s", sd.name, o.typename())
+	}
+
+	return str.String(), true, nil
+}
+
+// strof returns the string of the obj.
+// Unlike o.String(), the error in String method of the struct is returned.
+func strof(o obj) (string, error) {
+	if s, ok := o.(*oStruct); ok {
+		str, ok, err := s.def.str(s)
+		if err != nil {
+			return "", err
+		}
+
+		if ok {
+			return str, nil
+		}
+	}
+
+	return o.String(), nil
+}
@@ -6,6 +6,20 @@ type token struct {
 	typ tktype
 	lit string
 	loc *loc
+	// parts of f-string. only for tkFStr.
+	fparts []*fstrpart
+}
+
+// fstrpart is a part of f-string. It is either a literal string or an expression to be formatted.
+type fstrpart struct {
+	lit string
+	// source code of the expression. nil if the part is a literal.
+	expr []rune
+	// where the expression starts
+	loc *loc
+	// format spec after ":" in the braces, and where it starts
+	spec    string
+	specloc *loc
 }
 
 func (t *token) String() string {
@@ -25,6 +39,8 @@ func (t tktype) String() string {
 		return "ident"
 	case tkStr:
 		return "str"
+	case tkFStr:
+		return "fstr"
 	case tkNum:
 		return "num"
 	case tkEof:
@@ -91,24 +107,36 @@ const (
 	tkAmpEq                   // &=
 	tkVBarEq                  // |=
 	tkCaretEq                 // ^=
+	tk2Question               // ??
+	tkQuestDot                // ?.
 
 	// keywords
-	tkTrue     // true
-	tkFalse    // false
-	tkIf       // if
-	tkElif     // elif
-	tkElse     // else
-	tkFor      // for
-	tkIn       // in
-	tkDef      // def
-	tkContinue // continue
-	tkBreak    // break
-	tkReturn   // return
-	tkImport   // import
-	tkStruct   // struct
+	tkTrue      // true
+	tkFalse     // false
+	tkNil       // nil
+	tkIf        // if
+	tkElif      // elif
+	tkElse      // else
+	tkFor       // for
+	tkIn        // in
+	tkDef       // def
+	tkContinue  // continue
+	tkBreak     // break
+	tkReturn    // return
+	tkImport    // import
+	tkStruct    // struct
+	tkInterface // interface
+	tkEnum      // enum
+	tkTry       // try
+	tkCatch     // catch
+	tkDel       // del
+	tkNot       // not
+	tkMatch     // match
+	tkCase      // case
 
 	tkIdent
 	tkStr
+	tkFStr
 	tkNum
 	tkEof
 )
@@ -121,6 +149,7 @@ type strToTktype struct {
 var keywords = []*strToTktype{
 	{"true", tkTrue},
 	{"false", tkFalse},
+	{"nil", tkNil},
 	{"if", tkIf},
 	{"elif", tkElif},
 	{"else", tkElse},
@@ -131,7 +160,15 @@ var keywords = []*strToTktype{
 	{"break", tkBreak},
 	{"return", tkReturn},
 	{"import", tkImport},
+	{"try", tkTry},
+	{"catch", tkCatch},
+	{"del", tkDel},
+	{"not", tkNot},
+	{"match", tkMatch},
+	{"case", tkCase},
 	{"struct", tkStruct},
+	{"interface", tkInterface},
+	{"enum", tkEnum},
 }
 
 var punctuators = []*strToTktype{
@@ -150,6 +187,8 @@ var punctuators = []*strToTktype{
 	{"|=", tkVBarEq},
 	{"^=", tkCaretEq},
 	{":=", tkColonEq},
+	{"??", tk2Question},
+	{"?.", tkQuestDot},
 	{"<<", tk2Less},
 	{">>", tk2Greater},
 	{"<", tkLess},
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // tokenreader tokenizes the module and returns token one by one.
@@ -41,12 +44,18 @@ func (t *tokenreader) readtoken() (*token, error) {
 		t.next()
 	}
 
-	if t.cur() == '"' {
+	if t.cur() == 'f' && isquote(t.peek(1)) {
+		tk, err := t.readfstring()
+		return tk, err
+	}
+
+	if isquote(t.cur()) || (t.cur() == 'r' && isquote(t.peek(1))) {
 		tk, err := t.readstring()
 		return tk, err
 	}
 
-	if isdigit(t.cur()) {
+	// number might start with "." like ".5"
+	if isdigit(t.cur()) || (isdot(t.cur()) && isdigit(t.peek(1))) {
 		tk, err := t.readnum()
 		return tk, err
 	}
@@ -75,55 +84,443 @@ func (t *tokenreader) readtoken() (*token, error) {
 	return nil, newsberr2(loc, "invalid token")
 }
 
+// readstring reads string literal. Supported forms are:
+//
+//	"abc", 'abc':            escape sequences are interpreted. newline is not allowed.
+//	"""abc""", '''abc''': multi-line string. newlines are preserved, escape sequences are interpreted.
+//	r"abc", r"""abc""":      raw string. escape sequences are not interpreted.
 func (t *tokenreader) readstring() (*token, error) {
 	loc := t.newloc()
-	t.next() // skip left '"'
-	str := ""
-	// read until terminating " is found
-	// todo: handle intermediate quote
+
+	raw := false
+	if t.cur() == 'r' {
+		raw = true
+		t.next()
+	}
+
+	q := t.cur()
+	triple := t.peek(1) == q && t.peek(2) == q
+	if triple {
+		t.next()
+		t.next()
+	}
+	t.next() // skip left quote
+
+	var sb strings.Builder
 	for {
 		if !t.hasnext() {
 			return nil, newsberr2(loc, "string unterminated")
 		}
 
 		c := t.cur()
-		if c == '"' {
+		if c == q && (!triple || (t.peek(1) == q && t.peek(2) == q)) {
 			break
 		}
-		str += string(c)
+
+		if c == '\n' && !triple {
+			return nil, newsberr2(t.newloc(), "newline in string")
+		}
+
+		if c == '\\' && !raw {
+			if err := t.readescape(&sb); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// in raw string, quote after backslash does not terminate the string but the backslash is kept
+		if c == '\\' && raw && t.peek(1) == q {
+			sb.WriteRune(c)
+			t.next()
+			c = t.cur()
+		}
+
+		sb.WriteRune(c)
+		t.next()
+	}
+
+	// skip right quote
+	if triple {
+		t.next()
 		t.next()
 	}
-	t.next() // skip right '"'
+	t.next()
 
-	return t.newtoken(tkStr, str, loc), nil
+	return t.newtoken(tkStr, sb.String(), loc), nil
 }
 
-func (t *tokenreader) readnum() (*token, error) {
+// readfstring reads f-string such as f"a {b + 1} {c:>8.3f}".
+// The literal parts are read in the same way as readstring(), but "{{" and "}}" are read as "{" and "}".
+// The expression parts in braces are not tokenized here; they are kept as the source code with its location,
+// then parsed in parser.
+func (t *tokenreader) readfstring() (*token, error) {
 	loc := t.newloc()
-	s := string(t.cur())
+	t.next() // skip "f"
+
+	q := t.cur()
+	triple := t.peek(1) == q && t.peek(2) == q
+	if triple {
+		t.next()
+		t.next()
+	}
+	t.next() // skip left quote
+
+	parts := []*fstrpart{}
+	var sb strings.Builder
 	for {
 		if !t.hasnext() {
+			return nil, newsberr2(loc, "string unterminated")
+		}
+
+		c := t.cur()
+		if c == q && (!triple || (t.peek(1) == q && t.peek(2) == q)) {
 			break
 		}
 
+		if c == '\n' && !triple {
+			return nil, newsberr2(t.newloc(), "newline in string")
+		}
+
+		if c == '\\' {
+			if err := t.readescape(&sb); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if (c == '{' || c == '}') && t.peek(1) == c {
+			sb.WriteRune(c)
+			t.next()
+			t.next()
+			continue
+		}
+
+		if c == '}' {
+			return nil, newsberr2(t.newloc(), "single '}' is not allowed in f-string")
+		}
+
+		if c == '{' {
+			if sb.Len() > 0 {
+				parts = append(parts, &fstrpart{lit: sb.String()})
+				sb.Reset()
+			}
+
+			part, err := t.readfstrexpr(triple)
+			if err != nil {
+				return nil, err
+			}
+
+			parts = append(parts, part)
+			continue
+		}
+
+		sb.WriteRune(c)
 		t.next()
-		c := t.cur()
+	}
+
+	if sb.Len() > 0 {
+		parts = append(parts, &fstrpart{lit: sb.String()})
+	}
+
+	// skip right quote
+	if triple {
+		t.next()
+		t.next()
+	}
+	t.next()
+
+	tk := t.newtoken(tkFStr, "", loc)
+	tk.fparts = parts
+	return tk, nil
+}
+
+// readfstrexpr reads "{expr:spec}" in f-string.
+func (t *tokenreader) readfstrexpr(triple bool) (*fstrpart, error) {
+	loc := t.newloc()
+	t.next() // skip "{"
 
-		if !isdigit(c) && !isdot(c) {
+	part := &fstrpart{loc: t.newloc(), expr: []rune{}}
+	// nest level of brackets in the expression. ":" and "}" in nested brackets belong to the expression.
+	depth := 0
+	for {
+		if !t.hasnext() || (t.cur() == '\n' && !triple) {
+			return nil, newsberr2(loc, "'}' is expected in f-string")
+		}
+
+		c := t.cur()
+		if depth == 0 && (c == '}' || c == ':') {
 			break
 		}
 
-		s += string(c)
+		switch c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		}
+
+		// string literal in the expression is read as it is
+		if isquote(c) {
+			strloc := t.newloc()
+			part.expr = append(part.expr, c)
+			t.next()
+			for {
+				if !t.hasnext() || t.cur() == '\n' {
+					return nil, newsberr2(strloc, "string unterminated")
+				}
+
+				sc := t.cur()
+				part.expr = append(part.expr, sc)
+				t.next()
+
+				if sc == '\\' && t.hasnext() {
+					part.expr = append(part.expr, t.cur())
+					t.next()
+					continue
+				}
+
+				if sc == c {
+					break
+				}
+			}
+			continue
+		}
+
+		part.expr = append(part.expr, c)
+		t.next()
+	}
+
+	if strings.TrimSpace(string(part.expr)) == "" {
+		return nil, newsberr2(loc, "empty expression is not allowed in f-string")
+	}
+
+	if t.cur() == ':' {
+		t.next()
+		part.specloc = t.newloc()
+		for {
+			if !t.hasnext() || (t.cur() == '\n' && !triple) {
+				return nil, newsberr2(loc, "'}' is expected in f-string")
+			}
+
+			if t.cur() == '}' {
+				break
+			}
+
+			part.spec += string(t.cur())
+			t.next()
+		}
+	}
+
+	t.next() // skip "}"
+	return part, nil
+}
+
+// readescape reads an escape sequence starting with backslash and writes the result to sb.
+func (t *tokenreader) readescape(sb *strings.Builder) error {
+	loc := t.newloc()
+	t.next() // skip backslash
+	if !t.hasnext() {
+		return newsberr2(loc, "string unterminated")
+	}
+
+	c := t.cur()
+	t.next()
+
+	switch c {
+	case 'n':
+		sb.WriteByte('\n')
+	case 't':
+		sb.WriteByte('\t')
+	case 'r':
+		sb.WriteByte('\r')
+	case '0':
+		sb.WriteByte(0)
+	case 'a':
+		sb.WriteByte('\a')
+	case 'b':
+		sb.WriteByte('\b')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'v':
+		sb.WriteByte('\v')
+	case '\\', '"', '\'':
+		sb.WriteRune(c)
+	case '\n':
+		// backslash at the end of line joins the next line
+	case 'x':
+		// \xHH is a single byte
+		hex := string(t.peek(0)) + string(t.peek(1))
+		b, err := strconv.ParseUint(hex, 16, 8)
+		if err != nil {
+			return newsberr2(loc, "invalid escape sequence: \\x must be followed by 2 hexadecimal digits")
+		}
+		sb.WriteByte(byte(b))
+		t.next()
+		t.next()
+	case 'u':
+		// \u{H...} is a unicode code point
+		if t.peek(0) != '{' {
+			return newsberr2(loc, "invalid escape sequence: \\u must be followed by {code point}")
+		}
+		t.next()
+
+		hex := ""
+		for t.hasnext() && t.cur() != '}' {
+			hex += string(t.cur())
+			t.next()
+		}
+
+		if !t.hasnext() {
+			return newsberr2(loc, "invalid escape sequence: \\u{ is not closed")
+		}
+		t.next() // skip "}"
+
+		cp, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) > 6 || !utf8.ValidRune(rune(cp)) {
+			return newsberr2(loc, "invalid unicode code point \\u{%s}", hex)
+		}
+		sb.WriteRune(rune(cp))
+	default:
+		return newsberr2(loc, "unknown escape sequence \\%c", c)
 	}
 
-	dots := strings.Count(s, ".")
-	if dots >= 2 {
-		return nil, newsberr2(loc, "invalid decimal expression")
+	return nil
+}
+
+// readnum reads number literal. Supported forms are:
+//
+//	decimal:     123, 1_000_000
+//	hexadecimal: 0x1F
+//	octal:       0o777
+//	binary:      0b1010
+//	float:       1.5, .5, 1.5e-3, 1e10
+//
+// "_" can be put between digits (and right after the base prefix) as a separator.
+// The literal is returned as it is, conversion to the value is done in parser.
+func (t *tokenreader) readnum() (*token, error) {
+	loc := t.newloc()
+	s := ""
+
+	base := 10
+	if t.cur() == '0' {
+		switch t.peek(1) {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+
+		if base != 10 {
+			s += string(t.cur()) + string(t.peek(1))
+			t.next()
+			t.next()
+		}
+	}
+
+	digits, err := t.readdigits(base, base != 10)
+	if err != nil {
+		return nil, err
+	}
+	s += digits
+
+	if base != 10 {
+		if digits == "" || digits == "_" {
+			return nil, newsberr2(t.newloc(), "%s literal has no digits", basename(base))
+		}
+
+		if t.hasnext() && isidentletter(t.cur()) {
+			return nil, newsberr2(t.newloc(), "invalid digit %q in %s literal", t.cur(), basename(base))
+		}
+
+		return t.newtoken(tkNum, s, loc), nil
+	}
+
+	isfloat := false
+
+	// fraction
+	if t.hasnext() && isdot(t.cur()) && isdigit(t.peek(1)) {
+		isfloat = true
+		s += "."
+		t.next()
+		fraction, err := t.readdigits(10, false)
+		if err != nil {
+			return nil, err
+		}
+		s += fraction
+
+		if t.hasnext() && isdot(t.cur()) && isdigit(t.peek(1)) {
+			return nil, newsberr2(t.newloc(), "invalid decimal expression")
+		}
+	}
+
+	// exponent
+	if t.hasnext() && (t.cur() == 'e' || t.cur() == 'E') {
+		isfloat = true
+		s += string(t.cur())
+		t.next()
+		if t.hasnext() && (t.cur() == '+' || t.cur() == '-') {
+			s += string(t.cur())
+			t.next()
+		}
+
+		if !t.hasnext() || !isdigit(t.cur()) {
+			return nil, newsberr2(t.newloc(), "exponent has no digits")
+		}
+
+		exp, err := t.readdigits(10, false)
+		if err != nil {
+			return nil, err
+		}
+		s += exp
+	}
+
+	if t.hasnext() && isidentletter(t.cur()) {
+		return nil, newsberr2(t.newloc(), "invalid character %q in number literal", t.cur())
+	}
+
+	if !isfloat && len(digits) > 1 && digits[0] == '0' && strings.Trim(digits, "0_") != "" {
+		return nil, newsberr2(loc, "leading zeros in decimal integer literal are not permitted; use 0o prefix for octal")
 	}
 
 	return t.newtoken(tkNum, s, loc), nil
 }
 
+// readdigits reads digits in the base. "_" is allowed only between digits,
+// or at the head if leadingsep is true (for the case like 0x_FF).
+// Reading stops at the first non digit letter and it is left to the caller.
+func (t *tokenreader) readdigits(base int, leadingsep bool) (string, error) {
+	s := ""
+	for t.hasnext() {
+		c := t.cur()
+		if c == '_' {
+			prevok := (s == "" && leadingsep) || (s != "" && s[len(s)-1] != '_')
+			if !prevok || !isdigitof(t.peek(1), base) {
+				return "", newsberr2(t.newloc(), "'_' must separate successive digits")
+			}
+
+			s += "_"
+			t.next()
+			continue
+		}
+
+		if !isdigitof(c, base) {
+			// a decimal digit which is invalid in the base, such as 0b102
+			if isdigit(c) {
+				return "", newsberr2(t.newloc(), "invalid digit %q in %s literal", c, basename(base))
+			}
+
+			break
+		}
+
+		s += string(c)
+		t.next()
+	}
+
+	return s, nil
+}
+
 func (t *tokenreader) readident() (*token, bool) {
 	loc := t.newloc()
 	ident := ""
@@ -189,20 +586,23 @@ func (t *tokenreader) cur() rune {
 }
 
 func (t *tokenreader) next() {
-	t.pos++
-	t.col++
-
-	if !t.hasnext() {
-		return
-	}
-
-	if t.cur() == '\n' {
-		t.col = 0 // col gets 1 on upcoming next() call
+	// "\n" belongs to the line it terminates, the line number gets incremented after passing it.
+	if t.hasnext() && t.cur() == '\n' {
 		t.line++
+		t.col = 1
+	} else {
+		t.col++
 	}
+
+	t.pos++
 }
 
+// peek returns the rune n runes ahead of the cursor, or 0 if it is out of the content.
 func (t *tokenreader) peek(n int) rune {
+	if len(t.mod.content) <= t.pos+n {
+		return 0
+	}
+
 	return t.mod.content[t.pos+n]
 }
 
@@ -210,6 +610,32 @@ func isdigit(r rune) bool {
 	return '0' <= r && r <= '9'
 }
 
+func isdigitof(r rune, base int) bool {
+	switch base {
+	case 2:
+		return r == '0' || r == '1'
+	case 8:
+		return '0' <= r && r <= '7'
+	case 16:
+		return isdigit(r) || ('a' <= r && r <= 'f') || ('A' <= r && r <= 'F')
+	default:
+		return isdigit(r)
+	}
+}
+
+func basename(base int) string {
+	switch base {
+	case 2:
+		return "binary"
+	case 8:
+		return "octal"
+	case 16:
+		return "hexadecimal"
+	default:
+		return fmt.Sprintf("base %d", base)
+	}
+}
+
 func isidentletter(r rune) bool {
 	return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') || r == '_'
 }
@@ -218,6 +644,10 @@ func isspace(r rune) bool {
 	return r == ' ' || r == '\t'
 }
 
+func isquote(r rune) bool {
+	return r == '"' || r == '\''
+}
+
 func isdot(r rune) bool {
 	return r == '.'
 }